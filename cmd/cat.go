@@ -1,11 +1,10 @@
 package cmd
 
 import (
-	"bytes"
-	"fmt"
+	"io"
+	"os"
+	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/dbgeek/elblogcat/logcat"
 	"github.com/dbgeek/elblogcat/logworker"
 	"github.com/spf13/cobra"
@@ -14,52 +13,121 @@ import (
 
 // catCmd represents the cat command
 var catCmd = &cobra.Command{
-	Use:   "cat",
+	Use:   "cat [file...]",
 	Short: "cat accesslog from s3",
 	Long: `Download the accesslog and cat it
 
-possible user these filter
+When files are given they are read instead of the accesslogs in s3, - reads
+from stdin. Both gzip compressed and plain text files are supported.
+
+possible user these filter, the regexp filters can be repeated and any of
+them can match. Each of them has an --exclude- counterpart that hides the
+rows that match, like --exclude-request-path /health
 * client-ip
 * elb-status-code
 * target-status-code
 * http-method
-`,
-	Run: func(cmd *cobra.Command, args []string) {
-		awsConfiguration := logworker.AWSconfiguration{Region: "eu-west-1"}
-		configuration := logworker.NewConfiguration()
+* user-agent, domain-name, target-group, trace-id, actions-executed and
+  error-reason
+* request-host, request-path-prefix, request-path, query-param and
+  request-protocol of the request url.
+  Rejected connections, written as "- - - ", have no url and never match them
+* min- and max- request-time, target-time, response-time, total-time,
+  received-bytes and sent-bytes. Rows where the target never responded, with
+  the processing times -1, never match a time threshold
+* client-cidr and target-cidr, networks like 10.0.0.0/8 or 2001:db8::/32, a
+  leading ! excludes the network. --cidr-file reads the networks from a file
+* where, an expression on the fields of the rows like
+  'elb_status_code >= 500 and not request.path startswith "/health"'
 
-		accessLogFilter := logworker.NewAccessLogFilter()
-		client := logworker.NewLogWorker(
-			&awsConfiguration,
-			&configuration,
-			&accessLogFilter,
-		)
+Alb, classic elb and nlb tls accesslogs are supported, the format is detected
+for each row unless --format is set.
 
-		for _, v := range client.List() {
-			buff := &aws.WriteAtBuffer{}
-			key := fmt.Sprintf("%s%s", accessLogFilter.AccesslogPath(configuration.Prefix), v)
-			_, err := client.S3Downloader.Download(buff, &s3.GetObjectInput{
-				Bucket: aws.String(viper.GetString("s3-bucket")),
-				Key:    aws.String(key),
-			})
-			if err != nil {
-				logworker.Logger.Fatalf("Failed to Download key: %v from s3. Got error: %v",
-					key,
-					err)
-			}
+With --sort the rows of all accesslogs are merged in time order, the accesslogs
+are read when the merge reach their time so the output is still streamed.
+`,
+	Run: func(cmd *cobra.Command, args []string) {
+		sortKey, err := logcat.SortKeyByName(viper.GetString("sort"))
+		if err != nil {
+			logworker.Logger.Fatalf("%v", err)
+		}
+		if len(args) > 0 {
+			catFiles(args, sortKey)
+			return
+		}
+		client, err := newLogWorker()
+		if err != nil {
+			logworker.Logger.Fatalf("%v", err)
+		}
+		opts, err := newCatOptions(client.AccessLogFilter.LogKind)
+		if err != nil {
+			logworker.Logger.Fatalf("%v", err)
+		}
+		opts.tagTargets(client.AccessLogFilter)
+		opts.rowFilter.StartTime = client.AccessLogFilter.StartTime
+		opts.rowFilter.EndTime = client.AccessLogFilter.EndTime
 
-			c := logcat.NewRowFilter()
-			b := bytes.NewBuffer(buff.Bytes())
-			a := logcat.Accesslog{
-				Content:     b,
-				RowFilter:   c,
-				PrintFields: viper.GetString("fields"),
+		keys, err := client.ListKeys()
+		if err != nil {
+			logworker.Logger.Fatalf("%v", err)
+		}
+		if sortKey != logcat.SortNone {
+			var sources []logcat.MergeSource
+			for _, key := range keys {
+				key := key
+				sources = append(sources, mergeSource(key, func() (io.ReadCloser, error) { return client.Open(key) }, opts))
 			}
-			a.Cat()
+			err = catSorted(sources, sortKey, viper.GetDuration("sort-window"), opts)
+		} else {
+			err = catKeys(client, keys, viper.GetInt("concurrency"), opts)
+		}
+		if err != nil {
+			logworker.Logger.Fatalf("%v", err)
 		}
 	},
 }
 
+func catFiles(files []string, sortKey logcat.SortKey) {
+	opts, err := newCatOptions(logworker.LogKind(viper.GetString("log-kind")))
+	if err != nil {
+		logworker.Logger.Fatalf("%v", err)
+	}
+	// the default time range is for s3, files are only filtered on the times that is given
+	filter, err := newAccessLogFilter()
+	if err != nil {
+		logworker.Logger.Fatalf("%v", err)
+	}
+	if viper.GetString("start-time") != "" || viper.GetString("since") != "" || viper.GetString("last") != "" {
+		opts.rowFilter.StartTime = filter.StartTime
+	}
+	if viper.GetString("end-time") != "" || viper.GetString("last") != "" {
+		opts.rowFilter.EndTime = filter.EndTime
+	}
+	if sortKey != logcat.SortNone {
+		var sources []logcat.MergeSource
+		for _, name := range files {
+			name := name
+			sources = append(sources, mergeSource(name, func() (io.ReadCloser, error) { return openFile(name) }, opts))
+		}
+		if err := catSorted(sources, sortKey, viper.GetDuration("sort-window"), opts); err != nil {
+			logworker.Logger.Fatalf("%v", err)
+		}
+		return
+	}
+	for _, name := range files {
+		content, err := openFile(name)
+		if err != nil {
+			logworker.Logger.Errorf("Failed to read %v. Got error: %v", name, err)
+			continue
+		}
+		err = catContent(name, content, os.Stdout, opts)
+		content.Close()
+		if err != nil {
+			logworker.Logger.Fatalf("%v", err)
+		}
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(catCmd)
 
@@ -72,14 +140,11 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	//catCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
-	catCmd.PersistentFlags().StringP("client-ip", "", ".*", "")
-	viper.BindPFlag("client-ip", catCmd.PersistentFlags().Lookup("client-ip"))
-	catCmd.PersistentFlags().StringP("elb-status-code", "", ".*", "")
-	viper.BindPFlag("elb-status-code", catCmd.PersistentFlags().Lookup("elb-status-code"))
-	catCmd.PersistentFlags().StringP("target-status-code", "", ".*", "")
-	viper.BindPFlag("target-status-code", catCmd.PersistentFlags().Lookup("target-status-code"))
-	catCmd.PersistentFlags().StringP("http-method", "", ".*", "")
-	viper.BindPFlag("http-method", catCmd.PersistentFlags().Lookup("http-method"))
-	catCmd.PersistentFlags().StringP("fields", "", "type timestamp elb client:port", "field to print")
-	viper.BindPFlag("fields", catCmd.PersistentFlags().Lookup("fields"))
+	catCmd.PersistentFlags().AddFlagSet(rowFlags)
+	catCmd.Flags().IntP("concurrency", "", 8, "nr of accesslogs that is downloaded and parsed in parallel")
+	viper.BindPFlag("concurrency", catCmd.Flags().Lookup("concurrency"))
+	catCmd.Flags().StringP("sort", "", "none", "order the rows of all accesslogs by: none, timestamp or request_creation_time")
+	viper.BindPFlag("sort", catCmd.Flags().Lookup("sort"))
+	catCmd.Flags().DurationP("sort-window", "", time.Minute, "how much the rows of one accesslog can be out of order with --sort")
+	viper.BindPFlag("sort-window", catCmd.Flags().Lookup("sort-window"))
 }
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strings"
+
+	"github.com/dbgeek/elblogcat/logcat"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// patternsValue is a flag that can be repeated, every value is one pattern.
+// Unlike a string slice flag the values are not split on comma so regexps like
+// 5\d{1,2} can be used. It is a stringSlice to viper so viper.GetStringSlice
+// return the patterns.
+type patternsValue struct {
+	patterns []string
+}
+
+func (p *patternsValue) Set(s string) error {
+	p.patterns = append(p.patterns, s)
+	return nil
+}
+
+func (p *patternsValue) Type() string {
+	return "stringSlice"
+}
+
+func (p *patternsValue) String() string {
+	if len(p.patterns) == 0 {
+		return ""
+	}
+	b := &bytes.Buffer{}
+	w := csv.NewWriter(b)
+	w.Write(p.patterns)
+	w.Flush()
+	return "[" + strings.TrimSuffix(b.String(), "\n") + "]"
+}
+
+// rowFlags is the flags that filter and print the rows, cat and tail share them
+var rowFlags = newRowFlags()
+
+func newRowFlags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("rows", pflag.ContinueOnError)
+	addPatternFlags(flags, "client-ip", "the regexp match the whole client ip")
+	addPatternFlags(flags, "elb-status-code", "the regexp match the whole elb_status_code")
+	addPatternFlags(flags, "target-status-code", "the regexp match the whole target_status_code")
+	addPatternFlags(flags, "http-method", "the regexp match the start of the request, like GET")
+	addPatternFlags(flags, "request-host", "the regexp match the whole host of the request url, case insensitive")
+	addPatternFlags(flags, "request-path-prefix", "the percent-decoded path of the request url starts with the value")
+	addPatternFlags(flags, "request-path", "the regexp match the whole percent-decoded path of the request url")
+	addPatternFlags(flags, "query-param", "the name or name=value parameter is in the query of the request url")
+	addPatternFlags(flags, "request-protocol", "the regexp match the http version of the request, like HTTP/2.0")
+	addPatternFlags(flags, "user-agent", "the regexp match anywhere in the user agent")
+	addPatternFlags(flags, "domain-name", "the regexp match the whole sni domain, case insensitive")
+	addPatternFlags(flags, "target-group", "the regexp match the whole name or arn of the target group")
+	addPatternFlags(flags, "trace-id", "the regexp match the whole trace id or one of its parts, like Root=1-58337281-1d84f3d73c47ec4e58577259")
+	addPatternFlags(flags, "actions-executed", "the regexp match one of the actions executed, like waf or lambda")
+	addPatternFlags(flags, "error-reason", "the regexp match the whole error reason, like LambdaUnhandled")
+	flags.StringSliceP("client-cidr", "", nil, "only print the rows with a client address in these networks, !<cidr> excludes a network. Can be repeated")
+	viper.BindPFlag("client-cidr", flags.Lookup("client-cidr"))
+	flags.StringSliceP("target-cidr", "", nil, "only print the rows with a target address in these networks, !<cidr> excludes a network. Can be repeated")
+	viper.BindPFlag("target-cidr", flags.Lookup("target-cidr"))
+	flags.StringSliceP("exclude-client-cidr", "", nil, "hide the rows with a client address in these networks. Can be repeated")
+	viper.BindPFlag("exclude-client-cidr", flags.Lookup("exclude-client-cidr"))
+	flags.StringSliceP("exclude-target-cidr", "", nil, "hide the rows with a target address in these networks. Can be repeated")
+	viper.BindPFlag("exclude-target-cidr", flags.Lookup("exclude-target-cidr"))
+	flags.StringP("cidr-file", "", "", "file with a network on each line, written as [client|target] [!]<cidr>")
+	viper.BindPFlag("cidr-file", flags.Lookup("cidr-file"))
+	for _, threshold := range []struct{ name, help string }{
+		{"request-time", "request_processing_time in seconds or as a duration like 250ms"},
+		{"target-time", "target_processing_time in seconds or as a duration like 250ms"},
+		{"response-time", "response_processing_time in seconds or as a duration like 250ms"},
+		{"total-time", "sum of the processing times in seconds or as a duration like 250ms"},
+		{"received-bytes", "received_bytes, like 1048576, 10MB or 1MiB"},
+		{"sent-bytes", "sent_bytes, like 1048576, 10MB or 1MiB"},
+	} {
+		for _, bound := range []string{"min", "max"} {
+			name := bound + "-" + threshold.name
+			flags.StringP(name, "", "", bound+" "+threshold.help)
+			viper.BindPFlag(name, flags.Lookup(name))
+		}
+	}
+	flags.StringP("where", "", "", `only print the rows that match the expression, like 'elb_status_code >= 500 and target_processing_time > 1.5'`)
+	viper.BindPFlag("where", flags.Lookup("where"))
+	flags.StringP("fields", "", logcat.DefaultFields, "field to print")
+	viper.BindPFlag("fields", flags.Lookup("fields"))
+	flags.StringP("format", "", "auto", "log format of the accesslogs: auto, alb, classic, nlb or connection")
+	viper.BindPFlag("format", flags.Lookup("format"))
+	flags.StringP("output-tz", "", "", "print the timestamps in this timezone, like Europe/Stockholm or Local. Default is as they are written in the accesslog")
+	viper.BindPFlag("output-tz", flags.Lookup("output-tz"))
+	flags.StringP("time-field", "", "timestamp", "time of the rows that must be between start-time and end-time: timestamp or request_creation_time")
+	viper.BindPFlag("time-field", flags.Lookup("time-field"))
+	return flags
+}
+
+// addPatternFlags adds the repeatable flag name and its exclude-name counterpart
+// to flags. A row match when any name pattern match and no exclude-name pattern match.
+func addPatternFlags(flags *pflag.FlagSet, name, usage string) {
+	for _, flag := range []struct{ name, usage string }{
+		{name, "only print the rows where " + usage + ". Can be repeated, any of them can match"},
+		{"exclude-" + name, "hide the rows where " + usage + ". Can be repeated"},
+	} {
+		flags.Var(&patternsValue{}, flag.name, flag.usage)
+		viper.BindPFlag(flag.name, flags.Lookup(flag.name))
+	}
+}
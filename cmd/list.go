@@ -18,16 +18,16 @@ var listCmd = &cobra.Command{
 	* loadbalancer id
 `,
 	Run: func(cmd *cobra.Command, args []string) {
-		awsConfiguration := logworker.AWSconfiguration{Region: "eu-west-1"}
-		configuration := logworker.NewConfiguration()
-		accessLogFilter := logworker.NewAccessLogFilter()
-		client := logworker.NewLogWorker(
-			&awsConfiguration,
-			&configuration,
-			&accessLogFilter,
-		)
-
-		for _, v := range client.List() {
+		client, err := newLogWorker()
+		if err != nil {
+			logworker.Logger.Fatalf("%v", err)
+		}
+
+		accessLogs, err := client.List()
+		if err != nil {
+			logworker.Logger.Fatalf("%v", err)
+		}
+		for _, v := range accessLogs {
 			fmt.Println(v)
 		}
 
@@ -3,7 +3,6 @@ package cmd
 import (
 	"fmt"
 	"os"
-	"time"
 
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
@@ -19,7 +18,7 @@ var rootCmd = &cobra.Command{
 	Long: `List & cat aws alb/elb accesslog that are stored in s3.
 	
 Filter output is possible by:
-* timerange for one day
+* timerange, spanning one or more days
 * loadbalancer id
 * loadbalancer ip-address
 * accesslog unique string
@@ -45,24 +44,50 @@ func init() {
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.elblogcat.yaml)")
-	rootCmd.PersistentFlags().StringP("aws-account-id", "a", "", "The AWS account ID of the owner.")
+	rootCmd.PersistentFlags().StringP("org-id", "", "", "The AWS Organization ID of buckets with the organization layout. A comma separated list, default is every organization in the bucket.")
+	viper.BindPFlag("org-id", rootCmd.PersistentFlags().Lookup("org-id"))
+	rootCmd.PersistentFlags().StringP("aws-account-id", "a", "", "The AWS account ID of the owner. A comma separated list or all to list every account in the bucket.")
 	viper.BindPFlag("aws-account-id", rootCmd.PersistentFlags().Lookup("aws-account-id"))
-	rootCmd.PersistentFlags().StringP("region", "r", "", "The region for your load balancer and S3 bucket.")
+	rootCmd.PersistentFlags().StringP("region", "r", "", "The region for your load balancer and S3 bucket. A comma separated list or all to list every region of the accounts.")
 	viper.BindPFlag("region", rootCmd.PersistentFlags().Lookup("region"))
+	rootCmd.PersistentFlags().StringP("profile", "", "", "The aws shared config profile to use.")
+	viper.BindPFlag("profile", rootCmd.PersistentFlags().Lookup("profile"))
+	rootCmd.PersistentFlags().StringP("role-arn", "", "", "Arn of a role to assume before accessing the bucket.")
+	viper.BindPFlag("role-arn", rootCmd.PersistentFlags().Lookup("role-arn"))
+	rootCmd.PersistentFlags().StringP("external-id", "", "", "External id used when assuming --role-arn.")
+	viper.BindPFlag("external-id", rootCmd.PersistentFlags().Lookup("external-id"))
 	rootCmd.PersistentFlags().StringP("load-balancer-id", "l", ".*", "The resource ID of the load balancer. If the resource ID contains any forward slashes (/), they are replaced with periods (.).")
 	viper.BindPFlag("load-balancer-id", rootCmd.PersistentFlags().Lookup("load-balancer-id"))
-	rootCmd.PersistentFlags().StringP("ip-address", "i", ".*", "The IP address of the load balancer node that handled the request. For an internal load balancer, this is a private IP address.")
+	rootCmd.PersistentFlags().StringP("ip-address", "i", ".*", "The IP address of the load balancer node that handled the request. For an internal load balancer, this is a private IP address. Network load balancer logs have no node ip and are only listed when the pattern match an empty ip, like the default .*")
 	viper.BindPFlag("ip-address", rootCmd.PersistentFlags().Lookup("ip-address"))
 	rootCmd.PersistentFlags().StringP("random-string", "s", ".*", "A system-generated random string.")
 	viper.BindPFlag("random-string", rootCmd.PersistentFlags().Lookup("random-string"))
 	rootCmd.PersistentFlags().StringP("s3-bucket", "b", ".*", "The name of the S3 bucket.")
 	viper.BindPFlag("s3-bucket", rootCmd.PersistentFlags().Lookup("s3-bucket"))
+	rootCmd.PersistentFlags().StringP("s3-endpoint", "", "", "Custom s3 endpoint, for s3 compatible stores like MinIO.")
+	viper.BindPFlag("s3-endpoint", rootCmd.PersistentFlags().Lookup("s3-endpoint"))
+	rootCmd.PersistentFlags().BoolP("s3-force-path-style", "", false, "Use path style addressing (endpoint/bucket/key) instead of virtual host style.")
+	viper.BindPFlag("s3-force-path-style", rootCmd.PersistentFlags().Lookup("s3-force-path-style"))
+	rootCmd.PersistentFlags().BoolP("s3-disable-ssl", "", false, "Use http instead of https to talk to the s3 endpoint.")
+	viper.BindPFlag("s3-disable-ssl", rootCmd.PersistentFlags().Lookup("s3-disable-ssl"))
+	rootCmd.PersistentFlags().StringP("source", "", "", "Where the logs are read from: s3://<bucket> or file:///<dir> with the same layout as the bucket. Default is the bucket of --s3-bucket.")
+	viper.BindPFlag("source", rootCmd.PersistentFlags().Lookup("source"))
 	rootCmd.PersistentFlags().StringP("s3-prefix", "p", ".*", "The prefix (logical hierarchy) in the bucket. If you don't specify a prefix, the logs are placed at the root level of the bucket.")
 	viper.BindPFlag("s3-prefix", rootCmd.PersistentFlags().Lookup("s3-prefix"))
-	rootCmd.PersistentFlags().StringP("start-time", "", defaultStartTime().Format("2006-01-02 15:04:05"), "")
+	rootCmd.PersistentFlags().StringP("key-template", "", "auto", "Layout of the log keys with the placeholders {prefix}, {org_id}, {account}, {region} and {date}. auto detects if the bucket use the organization layout.")
+	viper.BindPFlag("key-template", rootCmd.PersistentFlags().Lookup("key-template"))
+	rootCmd.PersistentFlags().StringP("start-time", "", "", "Start of the timerange: now, now-45m, today, yesterday, RFC3339, \"2006-01-02 15:04:05\" or 15:04. Default is today 00:00.")
 	viper.BindPFlag("start-time", rootCmd.PersistentFlags().Lookup("start-time"))
-	rootCmd.PersistentFlags().StringP("end-time", "", time.Now().Format("2006-01-02 15:04:05"), "")
+	rootCmd.PersistentFlags().StringP("end-time", "", "", "End of the timerange, in the same format as start-time. Default is now.")
 	viper.BindPFlag("end-time", rootCmd.PersistentFlags().Lookup("end-time"))
+	rootCmd.PersistentFlags().StringP("since", "", "", "Start of the timerange as a duration back from now like 45m, or a time like start-time.")
+	viper.BindPFlag("since", rootCmd.PersistentFlags().Lookup("since"))
+	rootCmd.PersistentFlags().StringP("last", "", "", "The timerange is the duration back from now, like 2h.")
+	viper.BindPFlag("last", rootCmd.PersistentFlags().Lookup("last"))
+	rootCmd.PersistentFlags().StringP("tz", "", "UTC", "Timezone of start-time and end-time, like Europe/Stockholm or Local.")
+	viper.BindPFlag("tz", rootCmd.PersistentFlags().Lookup("tz"))
+	rootCmd.PersistentFlags().StringP("log-kind", "", "access", "Kind of log to work on: access or connection.")
+	viper.BindPFlag("log-kind", rootCmd.PersistentFlags().Lookup("log-kind"))
 	rootCmd.PersistentFlags().Int64P("max-keys", "", 500, "control nr of keys that should be return from s3 api for each response.")
 	viper.BindPFlag("max-keys", rootCmd.PersistentFlags().Lookup("max-keys"))
 
@@ -96,9 +121,3 @@ func initConfig() {
 		fmt.Println("Using config file:", viper.ConfigFileUsed())
 	}
 }
-
-func defaultStartTime() time.Time {
-	t := time.Now().UTC()
-	d := 24 * time.Hour
-	return t.Truncate(d)
-}
@@ -1,13 +1,9 @@
 package cmd
 
 import (
-	"bytes"
-	"fmt"
+	"os"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/dbgeek/elblogcat/logcat"
 	"github.com/dbgeek/elblogcat/logworker"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -18,41 +14,36 @@ var tailCmd = &cobra.Command{
 	Use:   "tail",
 	Short: "Porman tail pool for new accesslogs for default every 1min",
 	Long: `
+The rows are filtered and printed like cat, see cat --help for the filters.
 `,
 	Run: func(cmd *cobra.Command, args []string) {
-		awsConfiguration := logworker.AWSconfiguration{Region: "eu-west-1"}
-		configuration := logworker.NewConfiguration()
-		accessLogFilter := logworker.NewAccessLogFilter()
-		client := logworker.NewLogWorker(
-			&awsConfiguration,
-			&configuration,
-			&accessLogFilter,
-		)
+		client, err := newLogWorker()
+		if err != nil {
+			logworker.Logger.Fatalf("%v", err)
+		}
+		opts, err := newCatOptions(client.AccessLogFilter.LogKind)
+		if err != nil {
+			logworker.Logger.Fatalf("%v", err)
+		}
+		opts.tagTargets(client.AccessLogFilter)
+		// tail goes on after the end time
+		opts.rowFilter.StartTime = client.AccessLogFilter.StartTime
+
 		logs := make(chan string, 1)
+		errs := make(chan error, 1)
 
-		client.Tail(logs)
+		client.Tail(logs, errs)
 
-		for v := range logs {
-			buff := &aws.WriteAtBuffer{}
-			key := fmt.Sprintf("%s%s", accessLogFilter.AccesslogPath(configuration.Prefix), v)
-			_, err := client.S3Downloader.Download(buff, &s3.GetObjectInput{
-				Bucket: aws.String(viper.GetString("s3-bucket")),
-				Key:    aws.String(key),
-			})
-			if err != nil {
-				logworker.Logger.Fatalf("Failed to Download key: %v from s3. Got error: %v",
-					key,
-					err)
+		go func() {
+			for err := range errs {
+				logworker.Logger.Warnf("Failed to poll for new accesslogs, retrying next polling interval. Got error: %v", err)
 			}
+		}()
 
-			c := logcat.NewRowFilter()
-			b := bytes.NewBuffer(buff.Bytes())
-			a := logcat.Accesslog{
-				Content:     b,
-				RowFilter:   c,
-				PrintFields: viper.GetString("fields"),
+		for v := range logs {
+			if err := catAccessLog(client, v, os.Stdout, opts); err != nil {
+				logworker.Logger.Fatalf("%v", err)
 			}
-			a.Cat()
 		}
 	},
 }
@@ -61,5 +52,5 @@ func init() {
 	rootCmd.AddCommand(tailCmd)
 	tailCmd.PersistentFlags().Duration("polling-interval", 60*time.Second, "")
 	viper.BindPFlag("polling-interval", tailCmd.PersistentFlags().Lookup("polling-interval"))
-
+	tailCmd.PersistentFlags().AddFlagSet(rowFlags)
 }
@@ -0,0 +1,482 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dbgeek/elblogcat/logcat"
+	"github.com/dbgeek/elblogcat/logworker"
+	"github.com/spf13/viper"
+)
+
+// newLogWorker creates the LogWorker from the flags shared by all commands
+func newLogWorker() (*logworker.LogWorker, error) {
+	awsConfiguration := logworker.AWSconfiguration{
+		Region:     sessionRegion(viper.GetString("region")),
+		Profile:    viper.GetString("profile"),
+		RoleARN:    viper.GetString("role-arn"),
+		ExternalID: viper.GetString("external-id"),
+
+		S3Endpoint:       viper.GetString("s3-endpoint"),
+		S3ForcePathStyle: viper.GetBool("s3-force-path-style"),
+		S3DisableSSL:     viper.GetBool("s3-disable-ssl"),
+	}
+	configuration := newConfiguration()
+	accessLogFilter, err := newAccessLogFilter()
+	if err != nil {
+		return nil, err
+	}
+	var opts []logworker.Option
+	source, bucket, err := newSource(viper.GetString("source"))
+	if err != nil {
+		return nil, err
+	}
+	if source != nil {
+		opts = append(opts, logworker.WithSource(source))
+	}
+	if bucket != "" {
+		configuration.Bucket = bucket
+	}
+	return logworker.NewLogWorker(
+		&awsConfiguration,
+		&configuration,
+		&accessLogFilter,
+		opts...,
+	)
+}
+
+// sessionRegion return the region of the aws session, the region of the
+// profile is used when --region is a list or all.
+func sessionRegion(region string) string {
+	if region == logworker.All || strings.Contains(region, ",") {
+		return ""
+	}
+	return region
+}
+
+// newAccessLogFilter return the AccessLogFilter of the flags
+func newAccessLogFilter() (logworker.AccessLogFilter, error) {
+	return logworker.NewAccessLogFilter(logworker.AccessLogFilterOptions{
+		OrgID:          viper.GetString("org-id"),
+		AwsAccountID:   viper.GetString("aws-account-id"),
+		Region:         viper.GetString("region"),
+		LoadBalancerID: viper.GetString("load-balancer-id"),
+		IPaddress:      viper.GetString("ip-address"),
+		RandomString:   viper.GetString("random-string"),
+		StartTime:      viper.GetString("start-time"),
+		EndTime:        viper.GetString("end-time"),
+		Since:          viper.GetString("since"),
+		Last:           viper.GetString("last"),
+		TZ:             viper.GetString("tz"),
+		LogKind:        viper.GetString("log-kind"),
+		KeyTemplate:    viper.GetString("key-template"),
+	})
+}
+
+// newSource return the Source for the --source url. A nil Source means s3,
+// the bucket is returned when it is part of the url.
+func newSource(sourceURL string) (logworker.Source, string, error) {
+	if sourceURL == "" {
+		return nil, "", nil
+	}
+	u, err := url.Parse(sourceURL)
+	if err != nil {
+		return nil, "", fmt.Errorf("bad source %q: %v", sourceURL, err)
+	}
+	switch u.Scheme {
+	case "s3":
+		return nil, u.Host, nil
+	case "file":
+		return logworker.NewFileSource(filepath.FromSlash(u.Host + u.Path)), "", nil
+	}
+	return nil, "", fmt.Errorf("bad source %q: scheme should be s3:// or file://", sourceURL)
+}
+
+func newConfiguration() logworker.Configuration {
+	return logworker.Configuration{
+		Bucket:          viper.GetString("s3-bucket"),
+		Prefix:          viper.GetString("s3-prefix"),
+		PollingInterval: viper.GetDuration("polling-interval"),
+		MaxKeys:         viper.GetInt64("max-keys"),
+	}
+}
+
+// catOptions is how the rows of the accesslogs are parsed, filtered and printed
+type catOptions struct {
+	format      logcat.Format
+	printFields string
+	rowFilter   logcat.Filter
+	location    *time.Location
+}
+
+func newRowFilter() (logcat.Filter, error) {
+	filter := logcat.Filter{
+		ClientIP:                viper.GetStringSlice("client-ip"),
+		ExcludeClientIP:         viper.GetStringSlice("exclude-client-ip"),
+		ElbStatusCode:           viper.GetStringSlice("elb-status-code"),
+		ExcludeElbStatusCode:    viper.GetStringSlice("exclude-elb-status-code"),
+		TargetStatusCode:        viper.GetStringSlice("target-status-code"),
+		ExcludeTargetStatusCode: viper.GetStringSlice("exclude-target-status-code"),
+		HTTPmethod:              viper.GetStringSlice("http-method"),
+		ExcludeHTTPmethod:       viper.GetStringSlice("exclude-http-method"),
+		Host:                    viper.GetStringSlice("request-host"),
+		ExcludeHost:             viper.GetStringSlice("exclude-request-host"),
+		PathPrefix:              viper.GetStringSlice("request-path-prefix"),
+		ExcludePathPrefix:       viper.GetStringSlice("exclude-request-path-prefix"),
+		Path:                    viper.GetStringSlice("request-path"),
+		ExcludePath:             viper.GetStringSlice("exclude-request-path"),
+		QueryParams:             viper.GetStringSlice("query-param"),
+		ExcludeQueryParams:      viper.GetStringSlice("exclude-query-param"),
+		Protocol:                viper.GetStringSlice("request-protocol"),
+		ExcludeProtocol:         viper.GetStringSlice("exclude-request-protocol"),
+		UserAgent:               viper.GetStringSlice("user-agent"),
+		ExcludeUserAgent:        viper.GetStringSlice("exclude-user-agent"),
+		DomainName:              viper.GetStringSlice("domain-name"),
+		ExcludeDomainName:       viper.GetStringSlice("exclude-domain-name"),
+		TargetGroup:             viper.GetStringSlice("target-group"),
+		ExcludeTargetGroup:      viper.GetStringSlice("exclude-target-group"),
+		TraceID:                 viper.GetStringSlice("trace-id"),
+		ExcludeTraceID:          viper.GetStringSlice("exclude-trace-id"),
+		ActionsExecuted:         viper.GetStringSlice("actions-executed"),
+		ExcludeActionsExecuted:  viper.GetStringSlice("exclude-actions-executed"),
+		ErrorReason:             viper.GetStringSlice("error-reason"),
+		ExcludeErrorReason:      viper.GetStringSlice("exclude-error-reason"),
+		ClientCIDR:              excludeCIDRs(viper.GetStringSlice("client-cidr"), viper.GetStringSlice("exclude-client-cidr")),
+		TargetCIDR:              excludeCIDRs(viper.GetStringSlice("target-cidr"), viper.GetStringSlice("exclude-target-cidr")),
+		RequestCreationTime:     viper.GetString("time-field") == "request_creation_time",
+		Where:                   viper.GetString("where"),
+	}
+	thresholds := []struct {
+		name      string
+		parse     func(string) (float64, error)
+		threshold *logcat.Threshold
+	}{
+		{"request-time", logcat.ParseSeconds, &filter.RequestTime},
+		{"target-time", logcat.ParseSeconds, &filter.TargetTime},
+		{"response-time", logcat.ParseSeconds, &filter.ResponseTime},
+		{"total-time", logcat.ParseSeconds, &filter.TotalTime},
+		{"received-bytes", logcat.ParseBytes, &filter.ReceivedBytes},
+		{"sent-bytes", logcat.ParseBytes, &filter.SentBytes},
+	}
+	for _, t := range thresholds {
+		var err error
+		if t.threshold.Min, err = thresholdValue("min-"+t.name, t.parse); err != nil {
+			return logcat.Filter{}, err
+		}
+		if t.threshold.Max, err = thresholdValue("max-"+t.name, t.parse); err != nil {
+			return logcat.Filter{}, err
+		}
+	}
+	return filter, nil
+}
+
+// excludeCIDRs appends the exclude networks to cidrs written as !<cidr>
+func excludeCIDRs(cidrs []string, exclude []string) []string {
+	for _, cidr := range exclude {
+		cidrs = append(cidrs, "!"+strings.TrimPrefix(strings.TrimSpace(cidr), "!"))
+	}
+	return cidrs
+}
+
+// thresholdValue return the value of the flag name, nil when it is not set
+func thresholdValue(name string, parse func(string) (float64, error)) (*float64, error) {
+	s := viper.GetString(name)
+	if s == "" {
+		return nil, nil
+	}
+	v, err := parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return &v, nil
+}
+
+// newCatOptions return the log format, the fields to print and the row filter
+// for the log kind. The time range of the row filter is not set.
+func newCatOptions(logKind logworker.LogKind) (catOptions, error) {
+	format, err := logcat.FormatByName(viper.GetString("format"))
+	if err != nil {
+		return catOptions{}, err
+	}
+	switch timeField := viper.GetString("time-field"); timeField {
+	case "timestamp", "request_creation_time":
+	default:
+		return catOptions{}, fmt.Errorf("unknown time field %q, should be timestamp or request_creation_time", timeField)
+	}
+	var location *time.Location
+	if tz := viper.GetString("output-tz"); tz != "" {
+		if location, err = time.LoadLocation(tz); err != nil {
+			return catOptions{}, fmt.Errorf("bad output timezone: %v", err)
+		}
+	}
+	rowFilter, err := newRowFilter()
+	if err != nil {
+		return catOptions{}, err
+	}
+	if name := viper.GetString("cidr-file"); name != "" {
+		if err := readCIDRFile(name, &rowFilter); err != nil {
+			return catOptions{}, err
+		}
+	}
+	if err := rowFilter.Validate(); err != nil {
+		return catOptions{}, err
+	}
+	printFields := viper.GetString("fields")
+	if logKind == logworker.LogKindConnection {
+		if format == logcat.FormatAuto {
+			format = logcat.FormatConnection
+		}
+		if printFields == logcat.DefaultFields {
+			printFields = logcat.DefaultConnectionFields
+		}
+	}
+	return catOptions{format: format, printFields: printFields, rowFilter: rowFilter, location: location}, nil
+}
+
+// readCIDRFile adds the networks in the file name to the client and target networks of filter
+func readCIDRFile(name string, filter *logcat.Filter) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	client, target, err := logcat.ReadCIDRFile(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	filter.ClientCIDR = append(filter.ClientCIDR, client...)
+	filter.TargetCIDR = append(filter.TargetCIDR, target...)
+	return nil
+}
+
+// tagTargets prepends the account and region to the fields to print when the
+// logs of more than one account or region is printed.
+func (o *catOptions) tagTargets(filter *logworker.AccessLogFilter) {
+	if filter.MultipleTargets() {
+		o.printFields = "aws_account_id region " + o.printFields
+	}
+}
+
+// catKeys cat the accesslogs with concurrency of them downloaded and parsed in
+// parallel. The output is written in the order of keys, the accesslog that is
+// first in turn is written as it is read and the others are spooled until it
+// is their turn.
+func catKeys(client *logworker.LogWorker, keys []string, concurrency int, opts catOptions) error {
+	type job struct {
+		out  *spool
+		done chan error
+	}
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	jobs := make(chan job, concurrency-1)
+	stop := make(chan struct{})
+	go func() {
+		defer close(jobs)
+		for _, key := range keys {
+			j := job{out: newSpool(maxSpoolSize), done: make(chan error, 1)}
+			select {
+			case jobs <- j:
+			case <-stop:
+				return
+			}
+			go func(key string) {
+				j.done <- catAccessLog(client, key, j.out, opts)
+			}(key)
+		}
+	}()
+	var err error
+	for j := range jobs {
+		if err != nil {
+			// unblock the accesslogs that wait for their turn
+			j.out.abort()
+			continue
+		}
+		if err = j.out.writeThrough(os.Stdout); err == nil {
+			err = <-j.done
+		}
+		if err != nil {
+			close(stop)
+		}
+	}
+	return err
+}
+
+// maxSpoolSize is how much output an accesslog can spool before it waits for its turn
+const maxSpoolSize = 4 << 20
+
+// errSpoolAborted is returned by Write when the spool will never get its turn
+var errSpoolAborted = errors.New("spool aborted")
+
+// spool holds the output written to it until writeThrough is called, from
+// then on it is written directly to the output. Write blocks while more than
+// limit bytes is held so an accesslog is not read faster than it is written.
+type spool struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	buf     bytes.Buffer
+	limit   int
+	w       io.Writer
+	aborted bool
+}
+
+func newSpool(limit int) *spool {
+	s := &spool{limit: limit}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+func (s *spool) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for s.w == nil && !s.aborted && s.buf.Len() > 0 && s.buf.Len()+len(p) > s.limit {
+		s.cond.Wait()
+	}
+	if s.aborted {
+		return 0, errSpoolAborted
+	}
+	if s.w != nil {
+		return s.w.Write(p)
+	}
+	return s.buf.Write(p)
+}
+
+func (s *spool) writeThrough(w io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.cond.Broadcast()
+	if _, err := s.buf.WriteTo(w); err != nil {
+		s.aborted = true
+		return err
+	}
+	s.w = w
+	return nil
+}
+
+func (s *spool) abort() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.aborted = true
+	s.cond.Broadcast()
+}
+
+// catAccessLog stream the accesslog and print the rows that match the row filter to out.
+// Errors that is about this accesslog only are logged and nil is returned so the
+// caller can go on with the next one.
+func catAccessLog(client *logworker.LogWorker, key string, out io.Writer, opts catOptions) error {
+	content, err := client.Open(key)
+	if err != nil {
+		logworker.Logger.Errorf("Failed to Download key: %v. Got error: %v",
+			key,
+			err)
+		return nil
+	}
+	defer content.Close()
+
+	return catContent(key, content, out, opts)
+}
+
+// catContent print the rows of content that match the row filter to out. name is
+// only used in log messages. Errors that is about content are logged and nil is
+// returned, errors writing to out are returned.
+func catContent(name string, content io.Reader, out io.Writer, opts catOptions) error {
+	p := logcat.NewPrinter(out, opts.printFields)
+	p.Location = opts.location
+	var printErr error
+	err := newAccesslog(name, content, opts).Each(func(e logcat.Entry) error {
+		printErr = p.Print(e)
+		return printErr
+	})
+	if flushErr := p.Flush(); printErr == nil {
+		printErr = flushErr
+	}
+	if printErr != nil {
+		return printErr
+	}
+	if err != nil && !errors.Is(err, logcat.ErrBadFilter) {
+		logworker.Logger.Errorf("Failed to cat %v. Got error: %v", name, err)
+		return nil
+	}
+	return err
+}
+
+// catSorted print the rows of the accesslogs that match the row filter ordered by sortKey.
+// The accesslogs are merged as they are read, rows out of order by more than window
+// are printed when they are read.
+func catSorted(sources []logcat.MergeSource, sortKey logcat.SortKey, window time.Duration, opts catOptions) error {
+	sort.SliceStable(sources, func(i, j int) bool {
+		return sources[i].Start.Before(sources[j].Start)
+	})
+	m := logcat.Merge{Key: sortKey, Window: window}
+	p := logcat.NewPrinter(os.Stdout, opts.printFields)
+	p.Location = opts.location
+	if err := m.Run(sources, p.Print); err != nil {
+		p.Flush()
+		return err
+	}
+	return p.Flush()
+}
+
+// mergeSource return the accesslog with name as a source of catSorted. Errors that
+// is about this accesslog only are logged and the merge go on with the others.
+func mergeSource(name string, open func() (io.ReadCloser, error), opts catOptions) logcat.MergeSource {
+	src := logcat.MergeSource{}
+	if logName, err := logworker.ParseAccessLogName(path.Base(name)); err == nil {
+		src.Start = logName.EndTime.Add(-5 * time.Minute)
+	}
+	src.Each = func(fn func(e logcat.Entry) error) error {
+		content, err := open()
+		if err != nil {
+			logworker.Logger.Errorf("Failed to open %v. Got error: %v", name, err)
+			return nil
+		}
+		defer content.Close()
+		var fnErr error
+		err = newAccesslog(name, content, opts).Each(func(e logcat.Entry) error {
+			fnErr = fn(e)
+			return fnErr
+		})
+		if err != nil && fnErr == nil && !errors.Is(err, logcat.ErrBadFilter) {
+			logworker.Logger.Errorf("Failed to cat %v. Got error: %v", name, err)
+			return nil
+		}
+		return err
+	}
+	return src
+}
+
+// newAccesslog return an Accesslog of content with the format and row filter of opts. The rows
+// are tagged with the account and region when name is the name of a log in s3.
+func newAccesslog(name string, content io.Reader, opts catOptions) *logcat.Accesslog {
+	a := &logcat.Accesslog{
+		Content:   content,
+		RowFilter: opts.rowFilter,
+		Format:    opts.format,
+		OnBadRow: func(err error) {
+			logworker.Logger.Warnf("failed to parse row in %v: %v", name, err)
+		},
+	}
+	if logName, err := logworker.ParseAccessLogName(path.Base(name)); err == nil {
+		a.AwsAccountID, a.Region = logName.AwsAccountID, logName.Region
+	}
+	return a
+}
+
+// openFile opens the file with name, - is stdin
+func openFile(name string) (io.ReadCloser, error) {
+	if name == "-" {
+		return ioutil.NopCloser(os.Stdin), nil
+	}
+	return os.Open(name)
+}
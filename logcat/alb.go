@@ -0,0 +1,127 @@
+package logcat
+
+// ALB log schema versions. AWS appends fields to the end of the row when
+// the log format is extended, a version is the number of fields it knows.
+const (
+	ALBSchemaBase           Schema = 18
+	ALBSchemaTLS            Schema = 20
+	ALBSchemaRules          Schema = 23
+	ALBSchemaErrorReason    Schema = 25
+	ALBSchemaTargetList     Schema = 27
+	ALBSchemaClassification Schema = 29
+	ALBSchemaConnTraceID    Schema = 30
+
+	// ALBSchemaLatest is the newest schema known by the parser
+	ALBSchemaLatest = ALBSchemaConnTraceID
+)
+
+var (
+	// albFields is the field names of the ALB row in the order they are written
+	albFields = []string{
+		"type",
+		"time",
+		"elb",
+		"client:port",
+		"target:port",
+		"request_processing_time",
+		"target_processing_time",
+		"response_processing_time",
+		"elb_status_code",
+		"target_status_code",
+		"received_bytes",
+		"sent_bytes",
+		"request",
+		"user_agent",
+		"ssl_cipher",
+		"ssl_protocol",
+		"target_group_arn",
+		"trace_id",
+		"domain_name",
+		"chosen_cert_arn",
+		"matched_rule_priority",
+		"request_creation_time",
+		"actions_executed",
+		"redirect_url",
+		"error_reason",
+		"target:port_list",
+		"target_status_code_list",
+		"classification",
+		"classification_reason",
+		"conn_trace_id",
+	}
+	// fieldAliases is alternative names accepted by --fields for every format.
+	// The misspelled and hyphenated names are kept so old invocations still work.
+	fieldAliases = map[string]string{
+		"conn-type":                "type",
+		"request_porecessing_time": "request_processing_time",
+		"respsone_processing_time": "response_processing_time",
+		"elbStatis_code":           "elb_status_code",
+		"targetStatus_code":        "target_status_code",
+		"send_bytes":               "sent_bytes",
+		"user-agent":               "user_agent",
+		"ssl-cipher":               "ssl_cipher",
+		"ssl-protocol":             "ssl_protocol",
+		"target-group-arn":         "target_group_arn",
+		"chose_cert_arn":           "chosen_cert_arn",
+		"marched_rule_priority":    "matched_rule_priority",
+		"action_executed":          "actions_executed",
+		"account":                  "aws_account_id",
+		"aws-account-id":           "aws_account_id",
+	}
+	// albAliases maps the classic elb names to the alb fields
+	albAliases = map[string]string{
+		"timestamp":               "time",
+		"backend:port":            "target:port",
+		"backend_processing_time": "target_processing_time",
+		"backend_status_code":     "target_status_code",
+	}
+)
+
+func parseALB(p *fieldParser, e *Entry) {
+	e.Schema = albSchemaOf(len(p.fields))
+	e.Type = p.str(0)
+	e.Time = p.time(1)
+	e.ELB = p.str(2)
+	e.ClientIP, e.ClientPort = p.hostPort(3)
+	e.TargetIP, e.TargetPort = p.hostPort(4)
+	e.RequestProcessingTime = p.float(5)
+	e.TargetProcessingTime = p.float(6)
+	e.ResponseProcessingTime = p.float(7)
+	e.ELBStatusCode = p.int(8)
+	e.TargetStatusCode = p.int(9)
+	e.ReceivedBytes = p.int64(10)
+	e.SentBytes = p.int64(11)
+	e.Request = p.str(12)
+	e.RequestMethod, e.RequestURL, e.RequestProtocol = splitRequest(e.Request)
+	e.UserAgent = p.str(13)
+	e.SSLCipher = p.str(14)
+	e.SSLProtocol = p.str(15)
+	e.TargetGroupARN = p.str(16)
+	e.TraceID = p.str(17)
+	e.DomainName = p.str(18)
+	e.ChosenCertARN = p.str(19)
+	e.MatchedRulePriority = p.str(20)
+	e.RequestCreationTime = p.time(21)
+	e.ActionsExecuted = p.str(22)
+	e.RedirectURL = p.str(23)
+	e.ErrorReason = p.str(24)
+	e.TargetPortList = p.list(25)
+	e.TargetStatusCodeList = p.intList(26)
+	e.Classification = p.str(27)
+	e.ClassificationReason = p.str(28)
+	e.ConnTraceID = p.str(29)
+	if len(p.fields) > int(ALBSchemaLatest) {
+		e.ExtraFields = p.fields[ALBSchemaLatest:]
+	}
+}
+
+// albSchemaOf return the newest schema that is covered by n fields
+func albSchemaOf(n int) Schema {
+	schema := ALBSchemaBase
+	for _, s := range []Schema{ALBSchemaTLS, ALBSchemaRules, ALBSchemaErrorReason, ALBSchemaTargetList, ALBSchemaClassification, ALBSchemaConnTraceID} {
+		if n >= int(s) {
+			schema = s
+		}
+	}
+	return schema
+}
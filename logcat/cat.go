@@ -4,129 +4,415 @@ import (
 	"bufio"
 	"bytes"
 	"compress/gzip"
-	"encoding/csv"
+	"errors"
 	"fmt"
-	"os"
-	"regexp"
+	"io"
+	"io/ioutil"
 	"strings"
+	"sync"
 	"text/tabwriter"
+	"time"
+)
+
+const (
+	// DefaultFields is the fields printed for accesslogs when nothing else is asked for
+	DefaultFields = "type timestamp elb client:port"
+	// DefaultConnectionFields is the fields printed for connection logs when nothing else is asked for
+	DefaultConnectionFields = "timestamp client_ip client_port tls_verify_status"
+)
+
+var (
+	// ErrBadFilter is returned when the row Filter can not be compiled
+	ErrBadFilter = errors.New("bad row filter")
+	// ErrBadRow is returned when a row can not be parsed
+	ErrBadRow = errors.New("bad row")
 
-	"github.com/dbgeek/elblogcat/logworker"
-	"github.com/spf13/viper"
+	gzipMagic = []byte{0x1f, 0x8b}
 )
 
 type (
+	// Accesslog reads the rows of Content, gzip compressed or plain text,
+	// and pass on the ones that match the RowFilter as they are read
 	Accesslog struct {
-		Content     *bytes.Buffer
+		Content     io.Reader
 		RowFilter   Filter
 		PrintFields string
+		Format      Format
+		// Output is where the matched rows are printed, nothing is printed when it is nil
+		Output io.Writer
+		// OnBadRow is called with the error for each row that could not be parsed
+		OnBadRow func(err error)
+		// AwsAccountID and Region tag every entry, see the fields aws_account_id and region
+		AwsAccountID string
+		Region       string
+		// Location is the timezone timestamps are printed in, as they are written when nil
+		Location *time.Location
 	}
 	Filter struct {
-		ClientIP         string
-		ElbStatusCode    string
-		TargetStatusCode string
-		HTTPmethod       string
+		// The filters of a field is lists of regular expressions, a row match when
+		// any of them match and none of the Exclude ones match. ClientIP,
+		// ElbStatusCode and TargetStatusCode must match the whole value and
+		// HTTPmethod the start of the request.
+		ClientIP                []string
+		ExcludeClientIP         []string
+		ElbStatusCode           []string
+		ExcludeElbStatusCode    []string
+		TargetStatusCode        []string
+		ExcludeTargetStatusCode []string
+		HTTPmethod              []string
+		ExcludeHTTPmethod       []string
+		// Host, Path and Protocol must match the whole host, percent-decoded path
+		// and http version of the request url. PathPrefix is the start of the
+		// path and QueryParams is name or name=value parameters of the query.
+		Host               []string
+		ExcludeHost        []string
+		PathPrefix         []string
+		ExcludePathPrefix  []string
+		Path               []string
+		ExcludePath        []string
+		QueryParams        []string
+		ExcludeQueryParams []string
+		Protocol           []string
+		ExcludeProtocol    []string
+		// UserAgent match anywhere in the user agent, DomainName the whole sni
+		// domain case insensitive, TargetGroup the whole arn or name of the target
+		// group, TraceID the whole trace id or one of its parts like Root=,
+		// ActionsExecuted one of the actions and ErrorReason the whole reason.
+		UserAgent              []string
+		ExcludeUserAgent       []string
+		DomainName             []string
+		ExcludeDomainName      []string
+		TargetGroup            []string
+		ExcludeTargetGroup     []string
+		TraceID                []string
+		ExcludeTraceID         []string
+		ActionsExecuted        []string
+		ExcludeActionsExecuted []string
+		ErrorReason            []string
+		ExcludeErrorReason     []string
+		// ClientCIDR and TargetCIDR is the networks of the addresses, see CIDRSet
+		ClientCIDR []string
+		TargetCIDR []string
+		// The processing times in seconds, TotalTime is the sum of them, and the sizes in bytes
+		RequestTime   Threshold
+		TargetTime    Threshold
+		ResponseTime  Threshold
+		TotalTime     Threshold
+		ReceivedBytes Threshold
+		SentBytes     Threshold
+		// StartTime and EndTime is the time range of the rows, a zero time is no limit
+		StartTime time.Time
+		EndTime   time.Time
+		// RequestCreationTime filters on the request_creation_time of the rows
+		// instead of the timestamp, rows without it use the timestamp
+		RequestCreationTime bool
+		// Where is an expression on the fields of the rows, see compileWhere
+		Where string
 	}
 
-	entry struct {
-		row string
+	// Printer prints entries as tab separated rows. The rows are aligned in
+	// batches of printBatchRows rows, a batch is printed when it is full or
+	// printBatchDelay after its first row so rows that are streamed show up.
+	// The columns are only aligned within a batch and may shift between batches.
+	Printer struct {
+		// Location is the timezone timestamps are printed in, as they are written when nil
+		Location *time.Location
+
+		mu     sync.Mutex
+		tw     tabwriter.Writer
+		fields []string
+		rows   int
+		timer  *time.Timer
+		err    error
 	}
+
 	rowMatch struct {
-		matchString string
-		matcher     *regexp.Regexp
+		clientIP         *patternMatch
+		elbStatusCode    *patternMatch
+		targetStatusCode *patternMatch
+		httpMethod       *patternMatch
+		startTime        time.Time
+		endTime          time.Time
+		timeKey          SortKey
+		request          *requestMatch
+		fields           []fieldMatch
+		clientCIDR       *CIDRSet
+		targetCIDR       *CIDRSet
+		where            whereNode
+		requestTime      Threshold
+		targetTime       Threshold
+		responseTime     Threshold
+		totalTime        Threshold
+		receivedBytes    Threshold
+		sentBytes        Threshold
 	}
-	accessLogFieldPosition map[string]int
 )
 
-var (
-	fieldPosition = map[string]int{
-		"conn-type":                0,
-		"timestamp":                1,
-		"elb":                      2,
-		"client:port":              3,
-		"target:port":              4,
-		"request_porecessing_time": 5,
-		"target_processing_time":   6,
-		"respsone_processing_time": 7,
-		"elbStatis_code":           8,
-		"targetStatus_code":        9,
-		"received_bytes":           10,
-		"send_bytes":               11,
-		"request":                  12,
-		"user-agent":               13,
-		"ssl-cipher":               14,
-		"ssl-protocol":             15,
-		"target-group-arn":         16,
-		"trace_id":                 17,
-		"domain_name":              18,
-		"chose_cert_arn":           19,
-		"marched_rule_priority":    20,
-		"request_creation_time":    21,
-		"action_executed":          22,
-		"redirect_url":             23,
-		"error_reason":             24,
-	}
+// maxRowSize is the longest row that can be read, rows with long urls and
+// user agents are longer than the default of bufio.Scanner
+const maxRowSize = 1024 * 1024
+
+const (
+	// printBatchRows is the nr of rows that are aligned together
+	printBatchRows = 100
+	// printBatchDelay is the longest time a row waits for its batch to be full
+	printBatchDelay = 100 * time.Millisecond
 )
 
-func (a *Accesslog) Cat() {
-	//ToDO Break out this to it own method on the acceslog
-	var pFields []int
-	for _, v := range strings.Fields(a.PrintFields) {
-		pFields = append(pFields, fieldPosition[v])
+// Cat prints the fields in PrintFields of all rows that match the RowFilter.
+// Each row is printed as soon as it is matched. Rows that can not be parsed are skipped.
+func (a *Accesslog) Cat() error {
+	output := a.Output
+	if output == nil {
+		output = ioutil.Discard
+	}
+	p := NewPrinter(output, a.PrintFields)
+	p.Location = a.Location
+	if err := a.Each(p.Print); err != nil {
+		p.Flush()
+		return err
 	}
+	return p.Flush()
+}
 
-	gzReader, err := gzip.NewReader(a.Content)
-	if err != nil {
-		logworker.Logger.Fatalf("new gzip reader failed with: %v", err)
+// NewPrinter return a Printer of the space separated fields in printFields. The
+// columns are aligned in batches of rows, so they may shift between batches.
+func NewPrinter(w io.Writer, printFields string) *Printer {
+	p := &Printer{fields: strings.Fields(printFields)}
+	p.tw.Init(w, 0, 8, 2, '\t', 0)
+	return p
+}
+
+// Print writes the fields of e as one row, unknown fields are printed as -.
+// Flush must be called after the last row.
+func (p *Printer) Print(e Entry) error {
+	var str string
+	for _, name := range p.fields {
+		val, ok := e.Field(name)
+		if !ok {
+			val = "-"
+		}
+		if p.Location != nil {
+			val = inLocation(val, p.Location)
+		}
+		str += fmt.Sprintf("%s\t", val)
 	}
-	scanner := bufio.NewScanner(gzReader)
-	filter := newRowMatch(a.RowFilter)
-	var entries [][]string
-	for scanner.Scan() {
-		if filter.matcher.MatchString(scanner.Text()) {
-			//This should be break out to it´s own function
-			r := csv.NewReader(strings.NewReader(scanner.Text()))
-			r.Comma = ' '
-			field, err := r.Read()
-			if err != nil {
-				fmt.Println(err)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.err != nil {
+		return p.err
+	}
+	fmt.Fprintln(&p.tw, str)
+	p.rows++
+	if p.rows >= printBatchRows {
+		return p.flush()
+	}
+	if p.timer == nil {
+		p.timer = time.AfterFunc(printBatchDelay, func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			if p.rows > 0 {
+				p.flush()
 			}
-			entries = append(entries, field)
+		})
+	}
+	return nil
+}
+
+// Flush prints the rows that wait for their batch
+func (p *Printer) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.flush()
+}
+
+func (p *Printer) flush() error {
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	p.rows = 0
+	if err := p.tw.Flush(); err != nil && p.err == nil {
+		p.err = err
+	}
+	return p.err
+}
+
+// inLocation return val in loc with the same precision when it is a timestamp,
+// other values are returned as they are
+func inLocation(val string, loc *time.Location) string {
+	t, err := time.Parse(time.RFC3339Nano, val)
+	if err != nil {
+		if t, err = time.Parse(nlbTimeFormat, val); err != nil {
+			return val
 		}
 	}
-	tw := new(tabwriter.Writer)
-	tw.Init(os.Stdout, 0, 8, 2, '\t', 0)
-	defer tw.Flush()
-	for _, v := range entries {
-		var str string
-		for _, val := range pFields {
-			str += fmt.Sprintf("%s\t", v[val])
+	layout := "2006-01-02T15:04:05"
+	if i := strings.IndexByte(val, '.'); i >= 0 {
+		digits := strings.IndexFunc(val[i+1:], func(r rune) bool { return r < '0' || r > '9' })
+		if digits < 0 {
+			digits = len(val) - i - 1
 		}
-		fmt.Fprintln(tw, str)
+		layout += "." + strings.Repeat("0", digits)
 	}
+	return t.In(loc).Format(layout + "Z07:00")
+}
 
+// Entries return all entries that match the RowFilter
+func (a *Accesslog) Entries() ([]Entry, error) {
+	var entries []Entry
+	err := a.Each(func(e Entry) error {
+		entries = append(entries, e)
+		return nil
+	})
+	return entries, err
 }
-func newRowMatch(filter Filter) *rowMatch {
-	r := rowMatch{}
-	r.matchString = fmt.Sprintf("^(.*) (.*) (.*) (%s:.*) (.*) (.*) (.*) (.*) (%s) (%s) (.*) (.*) (\"%s.*) (.*) (.*) (.*) (.*) (.*) (.*) (.*) (.*) (.*) (.*) (.*) (.*) (.*)$",
-		filter.ClientIP,
-		filter.ElbStatusCode,
-		filter.TargetStatusCode,
-		filter.HTTPmethod)
-
-	regExp, err := regexp.Compile(r.matchString)
+
+// Each calls fn with every entry that match the RowFilter in the order they are
+// read from Content. Each stops at the first error returned by fn.
+func (a *Accesslog) Each(fn func(e Entry) error) error {
+	filter, err := newRowMatch(a.RowFilter)
+	if err != nil {
+		return err
+	}
+	content, err := decompress(a.Content)
 	if err != nil {
-		logworker.Logger.Fatalf("Failed rowmatch  compile regexp got error: %v", err)
+		return err
 	}
-	r.matcher = regExp
-	return &r
+	scanner := bufio.NewScanner(content)
+	scanner.Buffer(nil, maxRowSize)
+	for scanner.Scan() {
+		entry, err := ParseFormat(scanner.Bytes(), a.Format)
+		if err != nil {
+			if a.OnBadRow != nil {
+				a.OnBadRow(err)
+			}
+			continue
+		}
+		entry.AwsAccountID, entry.Region = a.AwsAccountID, a.Region
+		if !filter.match(&entry) {
+			continue
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read accesslog: %w", err)
+	}
+	return nil
 }
 
-func NewRowFilter() Filter {
-	return Filter{
-		ClientIP:         viper.GetString("client-ip"),
-		ElbStatusCode:    viper.GetString("elb-status-code"),
-		TargetStatusCode: viper.GetString("target-status-code"),
-		HTTPmethod:       viper.GetString("http-method"),
+// decompress return a reader of the uncompressed content, content that is
+// not gzip compressed is returned as it is.
+func decompress(content io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(content)
+	magic, err := br.Peek(len(gzipMagic))
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read accesslog: %w", err)
+	}
+	if !bytes.Equal(magic, gzipMagic) {
+		return br, nil
+	}
+	gzReader, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, fmt.Errorf("new gzip reader failed with: %w", err)
+	}
+	return gzReader, nil
+}
+
+func newRowMatch(filter Filter) (*rowMatch, error) {
+	r := rowMatch{
+		startTime:     filter.StartTime,
+		endTime:       filter.EndTime,
+		timeKey:       SortTime,
+		requestTime:   filter.RequestTime,
+		targetTime:    filter.TargetTime,
+		responseTime:  filter.ResponseTime,
+		totalTime:     filter.TotalTime,
+		receivedBytes: filter.ReceivedBytes,
+		sentBytes:     filter.SentBytes,
+	}
+	if filter.RequestCreationTime {
+		r.timeKey = SortRequestCreationTime
+	}
+	var err error
+	if r.clientIP, err = compilePatterns("client-ip", "^(?:%s)$", filter.ClientIP, filter.ExcludeClientIP); err != nil {
+		return nil, err
+	}
+	if r.elbStatusCode, err = compilePatterns("elb-status-code", "^(?:%s)$", filter.ElbStatusCode, filter.ExcludeElbStatusCode); err != nil {
+		return nil, err
+	}
+	if r.targetStatusCode, err = compilePatterns("target-status-code", "^(?:%s)$", filter.TargetStatusCode, filter.ExcludeTargetStatusCode); err != nil {
+		return nil, err
 	}
+	if r.httpMethod, err = compilePatterns("http-method", "^(?:%s)", filter.HTTPmethod, filter.ExcludeHTTPmethod); err != nil {
+		return nil, err
+	}
+	if r.request, err = newRequestMatch(filter); err != nil {
+		return nil, err
+	}
+	if r.fields, err = newFieldMatches(filter); err != nil {
+		return nil, err
+	}
+	if r.clientCIDR, err = ParseCIDRSet(filter.ClientCIDR); err != nil {
+		return nil, fmt.Errorf("%w: client-cidr: %v", ErrBadFilter, err)
+	}
+	if r.targetCIDR, err = ParseCIDRSet(filter.TargetCIDR); err != nil {
+		return nil, fmt.Errorf("%w: target-cidr: %v", ErrBadFilter, err)
+	}
+	if r.where, err = compileWhere(filter.Where); err != nil {
+		return nil, err
+	}
+	for _, t := range []struct {
+		name string
+		Threshold
+	}{
+		{"request-time", r.requestTime}, {"target-time", r.targetTime}, {"response-time", r.responseTime},
+		{"total-time", r.totalTime}, {"received-bytes", r.receivedBytes}, {"sent-bytes", r.sentBytes},
+	} {
+		if t.Min != nil && t.Max != nil && *t.Min > *t.Max {
+			return nil, fmt.Errorf("%w: min-%s is greater than max-%s", ErrBadFilter, t.name, t.name)
+		}
+	}
+	return &r, nil
+}
+
+// Validate return an ErrBadFilter error if the expressions of the filter can not be compiled
+func (f Filter) Validate() error {
+	_, err := newRowMatch(f)
+	return err
+}
+
+func (r *rowMatch) match(e *Entry) bool {
+	elbStatusCode, _ := e.Field("elb_status_code")
+	targetStatusCode, _ := e.Field("target_status_code")
+	return r.matchTime(e) &&
+		r.clientIP.match(e.ClientIP) &&
+		r.elbStatusCode.match(elbStatusCode) &&
+		r.targetStatusCode.match(targetStatusCode) &&
+		r.httpMethod.match(e.Request) &&
+		(r.request == nil || r.request.match(e)) &&
+		r.matchFields(e) &&
+		matchCIDR(r.clientCIDR, e.ClientIP) &&
+		matchCIDR(r.targetCIDR, e.TargetIP) &&
+		r.matchThresholds(e) &&
+		(r.where == nil || r.where.match(e))
+}
+
+// matchTime return true if the time of e is between the start and end time, both included
+func (r *rowMatch) matchTime(e *Entry) bool {
+	t := r.timeKey.of(e)
+	return (r.startTime.IsZero() || !t.Before(r.startTime)) &&
+		(r.endTime.IsZero() || !t.After(r.endTime))
+}
+
+// matchThresholds return true if the processing times and sizes of e are within the thresholds
+func (r *rowMatch) matchThresholds(e *Entry) bool {
+	return matchThreshold(r.requestTime, e, "request_processing_time") &&
+		matchThreshold(r.targetTime, e, "target_processing_time") &&
+		matchThreshold(r.responseTime, e, "response_processing_time") &&
+		matchTotalTime(r.totalTime, e) &&
+		matchThreshold(r.receivedBytes, e, "received_bytes") &&
+		matchThreshold(r.sentBytes, e, "sent_bytes")
 }
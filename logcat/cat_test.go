@@ -3,7 +3,12 @@ package logcat
 import (
 	"bytes"
 	"compress/gzip"
+	"errors"
+	"io"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestAccessLogFilter(t *testing.T) {
@@ -82,12 +87,15 @@ func TestAccessLogFilter(t *testing.T) {
 			a := Accesslog{}
 			a.Content = buff
 			a.RowFilter = Filter{
-				ClientIP:         tc.clientIP,
-				HTTPmethod:       tc.HTTPmethod,
-				ElbStatusCode:    tc.ElbStatusCode,
-				TargetStatusCode: tc.targetStatusCode,
+				ClientIP:         []string{tc.clientIP},
+				HTTPmethod:       []string{tc.HTTPmethod},
+				ElbStatusCode:    []string{tc.ElbStatusCode},
+				TargetStatusCode: []string{tc.targetStatusCode},
+			}
+			result, err := a.Entries()
+			if err != nil {
+				t.Fatalf("test: %s failed with error: %v", tc.name, err)
 			}
-			result := a.Cat()
 			if len(result) == 0 {
 				t.Fatalf("test: %s failed to find match", tc.name)
 			}
@@ -96,3 +104,212 @@ func TestAccessLogFilter(t *testing.T) {
 	}
 
 }
+
+func TestCatErrors(t *testing.T) {
+	a := Accesslog{Content: bytes.NewBuffer([]byte{0x1f, 0x8b, 0x00})}
+	if err := a.Cat(); err == nil {
+		t.Fatalf("cat of broken gzip content should fail")
+	}
+
+	a = Accesslog{Content: bytes.NewBuffer(nil), RowFilter: Filter{ElbStatusCode: []string{"(5"}}}
+	if err := a.Cat(); !errors.Is(err, ErrBadFilter) {
+		t.Fatalf("cat with bad filter should return ErrBadFilter; got %v", err)
+	}
+
+	if _, err := Parse([]byte("garbage")); !errors.Is(err, ErrBadRow) {
+		t.Fatalf("parse of garbage should return ErrBadRow; got %v", err)
+	}
+}
+
+func TestCatOutput(t *testing.T) {
+	buff := &bytes.Buffer{}
+	gw := gzip.NewWriter(buff)
+	gw.Write([]byte(testALBRow + "\nnot a row\n"))
+	gw.Close()
+
+	out := &bytes.Buffer{}
+	var badRows int
+	a := Accesslog{
+		Content:     buff,
+		PrintFields: "elb_status_code user_agent",
+		Output:      out,
+		OnBadRow:    func(err error) { badRows++ },
+	}
+	if err := a.Cat(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := out.String(), "200\tFaraday v0.9.2\t\n"; got != want {
+		t.Fatalf("output should be %q; got %q", want, got)
+	}
+	if badRows != 1 {
+		t.Fatalf("expected 1 bad row; got %d", badRows)
+	}
+}
+
+func TestCatAligned(t *testing.T) {
+	curlRow := strings.Replace(testALBRow, `"Faraday v0.9.2"`, `"curl"`, 1)
+	out := &bytes.Buffer{}
+	a := Accesslog{
+		Content:     bytes.NewBufferString(testALBRow + "\n" + curlRow + "\n"),
+		PrintFields: "user_agent elb_status_code",
+		Output:      out,
+	}
+	if err := a.Cat(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := out.String(), "Faraday v0.9.2\t200\t\ncurl\t\t200\t\n"; got != want {
+		t.Fatalf("the columns should be aligned as %q; got %q", want, got)
+	}
+}
+
+func TestCatPlainText(t *testing.T) {
+	a := Accesslog{Content: bytes.NewBufferString(testALBRow + "\n" + testClassicRow + "\n")}
+	entries, err := a.Entries()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 rows; got %d", len(entries))
+	}
+}
+
+func TestCatTags(t *testing.T) {
+	out := &bytes.Buffer{}
+	a := Accesslog{
+		Content:      bytes.NewBufferString(testALBRow + "\n"),
+		PrintFields:  "aws_account_id region elb_status_code",
+		Output:       out,
+		AwsAccountID: "0123456789",
+		Region:       "eu-west-1",
+	}
+	if err := a.Cat(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := out.String(), "0123456789\teu-west-1\t200\t\n"; got != want {
+		t.Fatalf("output should be %q; got %q", want, got)
+	}
+
+	a.Content = bytes.NewBufferString(testALBRow + "\n")
+	entries, err := a.Entries()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if account, ok := entries[0].Field("account"); !ok || account != "0123456789" {
+		t.Fatalf("account should be 0123456789; got %q", account)
+	}
+}
+
+func TestCatStreams(t *testing.T) {
+	pr, pw := io.Pipe()
+	out := make(chan string, 100)
+	a := Accesslog{
+		Content:     pr,
+		PrintFields: "elb_status_code",
+		Output:      writerFunc(func(p []byte) (int, error) { out <- string(p); return len(p), nil }),
+	}
+	errc := make(chan error, 1)
+	go func() { errc <- a.Cat() }()
+
+	// the first row is printed before the rest of the content is written
+	pw.Write([]byte(testALBRow + "\n"))
+	var got string
+	for !strings.HasSuffix(got, "\n") {
+		got += <-out
+	}
+	if got != "200\t\n" {
+		t.Fatalf("first row should be printed as %q; got %q", "200\t\n", got)
+	}
+	pw.Close()
+	if err := <-errc; err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+type writerFunc func(p []byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) { return f(p) }
+
+func TestCatTimeRange(t *testing.T) {
+	at := func(s string) time.Time {
+		tm, err := time.Parse(time.RFC3339Nano, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	tt := []struct {
+		name   string
+		filter Filter
+		out    int
+	}{
+		{"no-range", Filter{}, 2},
+		{"inside", Filter{StartTime: at("2019-02-02T00:14:00Z"), EndTime: at("2019-02-02T00:14:07.5Z")}, 1},
+		{"start-only", Filter{StartTime: at("2019-02-02T00:14:08Z")}, 1},
+		{"both", Filter{StartTime: at("2019-02-02T00:14:07.437021Z")}, 2},
+		{"end-only", Filter{EndTime: at("2019-02-02T00:14:07.437021Z")}, 1},
+		{"before", Filter{EndTime: at("2019-02-02T00:14:07Z")}, 0},
+		{"request-creation-time", Filter{EndTime: at("2019-02-02T00:14:07.436Z"), RequestCreationTime: true}, 1},
+		{"request-creation-time-after", Filter{StartTime: at("2019-02-02T00:14:07.436Z"), RequestCreationTime: true}, 1},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			a := Accesslog{Content: bytes.NewBufferString(testALBRow + "\n" + testALBRejectedRow + "\n"), RowFilter: tc.filter}
+			entries, err := a.Entries()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(entries) != tc.out {
+				t.Fatalf("expected %d rows; got %d", tc.out, len(entries))
+			}
+		})
+	}
+}
+
+func TestPrinterLocation(t *testing.T) {
+	loc := time.FixedZone("CET", 3600)
+	tt := []struct {
+		in  string
+		out string
+	}{
+		{"2019-02-02T00:14:07.437021Z", "2019-02-02T01:14:07.437021+01:00"},
+		{"2019-02-02T00:14:07.435000Z", "2019-02-02T01:14:07.435000+01:00"},
+		{"2019-02-02T00:14:07", "2019-02-02T01:14:07+01:00"},
+		{"Faraday v0.9.2", "Faraday v0.9.2"},
+		{"-", "-"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.in, func(t *testing.T) {
+			if got := inLocation(tc.in, loc); got != tc.out {
+				t.Fatalf("inLocation(%v) should be %v; got %v", tc.in, tc.out, got)
+			}
+		})
+	}
+
+	out := &bytes.Buffer{}
+	a := Accesslog{Content: bytes.NewBufferString(testALBRow + "\n"), PrintFields: "timestamp elb", Output: out, Location: loc}
+	if err := a.Cat(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := out.String(), "2019-02-02T01:14:07.437021+01:00\telb01\t\n"; got != want {
+		t.Fatalf("output should be %q; got %q", want, got)
+	}
+}
+
+// matchingRows return the sorted names of the rows that match filter, each row
+// is filtered on its own
+func matchingRows(t *testing.T, rows map[string]string, filter Filter) []string {
+	t.Helper()
+	var names []string
+	for name, row := range rows {
+		a := Accesslog{Content: strings.NewReader(row + "\n"), RowFilter: filter}
+		entries, err := a.Entries()
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", name, err)
+		}
+		if len(entries) == 1 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
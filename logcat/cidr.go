@@ -0,0 +1,158 @@
+package logcat
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+type (
+	// CIDRSet is a set of included and excluded networks, excluded networks are
+	// written with a leading !. An address is in the set when the longest network
+	// that contains it is included, or when no network contains it and the set
+	// has no included networks. IPv4 networks also match IPv4-mapped IPv6 addresses,
+	// IPv6 networks never match IPv4 addresses.
+	CIDRSet struct {
+		v4       cidrNode
+		v6       cidrNode
+		includes int
+	}
+
+	// cidrNode is a node of a binary trie over the bits of the 4 byte form of
+	// IPv4 addresses or the 16 byte form of IPv6 addresses
+	cidrNode struct {
+		child   [2]*cidrNode
+		set     bool
+		exclude bool
+	}
+)
+
+// ParseCIDRSet return the CIDRSet of the networks in cidrs, a nil set when cidrs is empty
+func ParseCIDRSet(cidrs []string) (*CIDRSet, error) {
+	if len(cidrs) == 0 {
+		return nil, nil
+	}
+	s := &CIDRSet{}
+	for _, cidr := range cidrs {
+		if err := s.Add(cidr); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Add adds the network cidr to the set, an address without prefix length is a
+// network of its own. A leading ! excludes the network.
+func (s *CIDRSet) Add(cidr string) error {
+	cidr = strings.TrimSpace(cidr)
+	exclude := strings.HasPrefix(cidr, "!")
+	network := strings.TrimSpace(strings.TrimPrefix(cidr, "!"))
+	if !strings.Contains(network, "/") {
+		if strings.Contains(network, ":") {
+			network += "/128"
+		} else {
+			network += "/32"
+		}
+	}
+	_, ipNet, err := net.ParseCIDR(network)
+	if err != nil {
+		return fmt.Errorf("bad cidr %q", cidr)
+	}
+	ones, bits := ipNet.Mask.Size()
+	n, ip := &s.v6, ipNet.IP.To16()
+	if ip4 := ipNet.IP.To4(); ip4 != nil && (bits == 8*net.IPv4len || ones >= 8*(net.IPv6len-net.IPv4len)) {
+		// IPv4 networks written as IPv4-mapped IPv6 networks, like ::ffff:10.0.0.0/104
+		if bits == 8*net.IPv6len {
+			ones -= 8 * (net.IPv6len - net.IPv4len)
+		}
+		n, ip = &s.v4, ip4
+	}
+	for i := 0; i < ones; i++ {
+		b := ip[i/8] >> uint(7-i%8) & 1
+		if n.child[b] == nil {
+			n.child[b] = &cidrNode{}
+		}
+		n = n.child[b]
+	}
+	if n.set && !n.exclude {
+		s.includes--
+	}
+	if !exclude {
+		s.includes++
+	}
+	n.set, n.exclude = true, exclude
+	return nil
+}
+
+// Contains return true if the address ip is in the set. Addresses that can not
+// be parsed, like - for rows without target, are not in any network.
+func (s *CIDRSet) Contains(ip string) bool {
+	addr := net.ParseIP(ip)
+	var longest *cidrNode
+	switch {
+	case addr == nil:
+	case addr.To4() != nil:
+		longest = s.v4.longest(addr.To4())
+	default:
+		longest = s.v6.longest(addr)
+	}
+	if longest == nil {
+		return s.includes == 0
+	}
+	return !longest.exclude
+}
+
+// longest return the longest network under n that contains addr, nil when no
+// network contains it
+func (n *cidrNode) longest(addr net.IP) *cidrNode {
+	var longest *cidrNode
+	for i := 0; n != nil; i++ {
+		if n.set {
+			longest = n
+		}
+		if i == 8*len(addr) {
+			break
+		}
+		n = n.child[addr[i/8]>>uint(7-i%8)&1]
+	}
+	return longest
+}
+
+// ReadCIDRFile reads a list of networks for the client and target addresses.
+// Each line is a network, optionally with a leading client or target, client is
+// the default. Empty lines and text after # are skipped.
+//
+//	10.0.0.0/8
+//	!10.1.0.0/16
+//	target 172.16.0.0/12
+func ReadCIDRFile(r io.Reader) (client []string, target []string, err error) {
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		if i := strings.Index(text, "#"); i >= 0 {
+			text = text[:i]
+		}
+		fields := strings.Fields(text)
+		switch {
+		case len(fields) == 0:
+		case len(fields) == 1:
+			client = append(client, fields[0])
+		case len(fields) == 2 && fields[0] == "client":
+			client = append(client, fields[1])
+		case len(fields) == 2 && fields[0] == "target":
+			target = append(target, fields[1])
+		default:
+			return nil, nil, fmt.Errorf("%w: cidr file: line %d: should be [client|target] [!]<cidr>", ErrBadFilter, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, err
+	}
+	return client, target, nil
+}
+
+func matchCIDR(s *CIDRSet, ip string) bool {
+	return s == nil || s.Contains(ip)
+}
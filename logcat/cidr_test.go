@@ -0,0 +1,111 @@
+package logcat
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCIDRSet(t *testing.T) {
+	tt := []struct {
+		name  string
+		cidrs []string
+		ip    string
+		out   bool
+	}{
+		{"ipv4-in", []string{"10.222.160.0/22"}, "10.222.161.42", true},
+		{"ipv4-out", []string{"10.222.160.0/22"}, "10.222.164.1", false},
+		{"ipv4-single-address", []string{"10.222.161.42"}, "10.222.161.42", true},
+		{"ipv4-other-address", []string{"10.222.161.42"}, "10.222.161.43", false},
+		{"any-of", []string{"192.168.0.0/16", "10.0.0.0/8"}, "10.1.2.3", true},
+		{"excluded", []string{"!10.0.0.0/8"}, "10.1.2.3", false},
+		{"not-excluded", []string{"!10.0.0.0/8"}, "192.168.1.1", true},
+		{"longest-exclude", []string{"10.0.0.0/8", "!10.1.0.0/16"}, "10.1.2.3", false},
+		{"longest-include", []string{"!10.0.0.0/8", "10.1.0.0/16"}, "10.1.2.3", true},
+		{"include-outside-exclude", []string{"10.0.0.0/8", "!10.1.0.0/16"}, "10.2.0.1", true},
+		{"ipv6-in", []string{"2001:db8::/32"}, "2001:db8::1", true},
+		{"ipv6-out", []string{"2001:db8::/32"}, "2001:db9::1", false},
+		{"ipv6-single-address", []string{"2001:db8::1"}, "2001:db8::1", true},
+		{"ipv4-not-in-ipv6", []string{"2001:db8::/32"}, "10.1.2.3", false},
+		{"ipv4-mapped", []string{"10.0.0.0/8"}, "::ffff:10.1.2.3", true},
+		{"all", []string{"0.0.0.0/0"}, "10.1.2.3", true},
+		{"no-address", []string{"10.0.0.0/8"}, "", false},
+		{"no-address-exclude", []string{"!10.0.0.0/8"}, "-", true},
+		{"redefined-as-exclude", []string{"10.0.0.0/8", "!10.0.0.0/8"}, "192.168.1.1", true},
+		{"redefined-as-include", []string{"!10.0.0.0/8", "10.0.0.0/8"}, "192.168.1.1", false},
+		{"redefined-as-include-in", []string{"!10.0.0.0/8", "10.0.0.0/8"}, "10.1.2.3", true},
+		{"ipv4-not-in-ipv6-all", []string{"::/0"}, "10.1.2.3", false},
+		{"ipv4-mapped-not-in-ipv6", []string{"::/8"}, "::ffff:10.1.2.3", false},
+		{"ipv6-all", []string{"::/0"}, "2001:db8::1", true},
+		{"ipv4-mapped-network", []string{"::ffff:10.0.0.0/104"}, "10.1.2.3", true},
+		{"ipv4-mapped-network-out", []string{"::ffff:10.0.0.0/104"}, "11.1.2.3", false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := ParseCIDRSet(tc.cidrs)
+			if err != nil {
+				t.Fatalf("parse %v failed: %v", tc.cidrs, err)
+			}
+			if got := s.Contains(tc.ip); got != tc.out {
+				t.Fatalf("%v contains %q should be %v", tc.cidrs, tc.ip, tc.out)
+			}
+		})
+	}
+
+	if _, err := ParseCIDRSet([]string{"10.0.0.0/33"}); err == nil {
+		t.Fatalf("parse of bad cidr should fail")
+	}
+	if s, err := ParseCIDRSet(nil); s != nil || err != nil {
+		t.Fatalf("parse of no cidrs should be a nil set; got %v, %v", s, err)
+	}
+}
+
+func TestReadCIDRFile(t *testing.T) {
+	client, target, err := ReadCIDRFile(strings.NewReader("# office\n10.0.0.0/8\n\n!10.1.0.0/16 # guests\nclient 2001:db8::/32\ntarget 172.16.0.0/12\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{"10.0.0.0/8", "!10.1.0.0/16", "2001:db8::/32"}; !reflect.DeepEqual(client, want) {
+		t.Errorf("client should be %v; got %v", want, client)
+	}
+	if want := []string{"172.16.0.0/12"}; !reflect.DeepEqual(target, want) {
+		t.Errorf("target should be %v; got %v", want, target)
+	}
+
+	if _, _, err := ReadCIDRFile(strings.NewReader("10.0.0.0/8\nbackend 10.0.0.0/8\n")); !errors.Is(err, ErrBadFilter) {
+		t.Fatalf("unknown address kind should fail with ErrBadFilter; got %v", err)
+	}
+}
+
+func TestCatCIDR(t *testing.T) {
+	rows := testALBRow + "\n" + testALBRejectedRow + "\n"
+	tt := []struct {
+		name   string
+		filter Filter
+		out    int
+	}{
+		{"client-ipv4", Filter{ClientCIDR: []string{"10.222.160.0/22"}}, 1},
+		{"client-ipv6", Filter{ClientCIDR: []string{"2001:db8::/32"}}, 1},
+		{"client-not", Filter{ClientCIDR: []string{"!10.222.160.0/22"}}, 1},
+		{"target", Filter{TargetCIDR: []string{"10.222.20.0/24"}}, 1},
+		{"target-not", Filter{TargetCIDR: []string{"!10.222.20.0/24"}}, 1},
+		{"both", Filter{ClientCIDR: []string{"10.0.0.0/8", "2001:db8::/32"}, TargetCIDR: []string{"!192.168.0.0/16"}}, 2},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			a := Accesslog{Content: strings.NewReader(rows), RowFilter: tc.filter}
+			entries, err := a.Entries()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(entries) != tc.out {
+				t.Fatalf("should match %d rows; got %d", tc.out, len(entries))
+			}
+		})
+	}
+
+	if err := (Filter{TargetCIDR: []string{"10.0.0.0/8", "10.0.0/8"}}).Validate(); !errors.Is(err, ErrBadFilter) {
+		t.Fatalf("bad cidr should fail with ErrBadFilter; got %v", err)
+	}
+}
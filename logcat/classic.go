@@ -0,0 +1,54 @@
+package logcat
+
+const (
+	// classicMinFields is the number of fields written before user_agent and
+	// the ssl fields were added to the classic elb log.
+	classicMinFields = 12
+)
+
+var (
+	// classicFields is the field names of the classic elb row in the order they are written
+	classicFields = []string{
+		"timestamp",
+		"elb",
+		"client:port",
+		"backend:port",
+		"request_processing_time",
+		"backend_processing_time",
+		"response_processing_time",
+		"elb_status_code",
+		"backend_status_code",
+		"received_bytes",
+		"sent_bytes",
+		"request",
+		"user_agent",
+		"ssl_cipher",
+		"ssl_protocol",
+	}
+	// classicAliases maps the alb names to the classic elb fields
+	classicAliases = map[string]string{
+		"time":                   "timestamp",
+		"target:port":            "backend:port",
+		"target_processing_time": "backend_processing_time",
+		"target_status_code":     "backend_status_code",
+	}
+)
+
+func parseClassic(p *fieldParser, e *Entry) {
+	e.Time = p.time(0)
+	e.ELB = p.str(1)
+	e.ClientIP, e.ClientPort = p.hostPort(2)
+	e.TargetIP, e.TargetPort = p.hostPort(3)
+	e.RequestProcessingTime = p.float(4)
+	e.TargetProcessingTime = p.float(5)
+	e.ResponseProcessingTime = p.float(6)
+	e.ELBStatusCode = p.int(7)
+	e.TargetStatusCode = p.int(8)
+	e.ReceivedBytes = p.int64(9)
+	e.SentBytes = p.int64(10)
+	e.Request = p.str(11)
+	e.RequestMethod, e.RequestURL, e.RequestProtocol = splitRequest(e.Request)
+	e.UserAgent = p.str(12)
+	e.SSLCipher = p.str(13)
+	e.SSLProtocol = p.str(14)
+}
@@ -0,0 +1,108 @@
+package logcat
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+)
+
+const (
+	testClassicRow = `2015-05-13T23:39:43.945958Z my-loadbalancer 192.168.131.39:2817 10.0.0.1:80 0.000073 0.001048 0.000057 200 200 0 29 "GET http://www.example.com:80/ HTTP/1.1" "curl/7.38.0" - -`
+)
+
+func TestParseClassic(t *testing.T) {
+	tt := []struct {
+		name   string
+		format Format
+	}{
+		{"auto", FormatAuto},
+		{"classic", FormatClassic},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e, err := ParseFormat([]byte(testClassicRow), tc.format)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if e.Format != FormatClassic {
+				t.Errorf("format: got %v", e.Format)
+			}
+			if e.ELB != "my-loadbalancer" || e.Type != "" {
+				t.Errorf("elb/type: got %q/%q", e.ELB, e.Type)
+			}
+			if e.TargetIP != "10.0.0.1" || e.TargetPort != 80 {
+				t.Errorf("backend: got %s:%d", e.TargetIP, e.TargetPort)
+			}
+			if e.TargetProcessingTime != 0.001048 || e.TargetStatusCode != 200 || e.SentBytes != 29 {
+				t.Errorf("backend_processing_time/backend_status_code/sent_bytes: got %v/%d/%d", e.TargetProcessingTime, e.TargetStatusCode, e.SentBytes)
+			}
+			if e.RequestMethod != "GET" || e.UserAgent != "curl/7.38.0" {
+				t.Errorf("request method/user_agent: got %q/%q", e.RequestMethod, e.UserAgent)
+			}
+		})
+	}
+}
+
+func TestParseClassicAsALB(t *testing.T) {
+	if _, err := ParseFormat([]byte(testClassicRow), FormatALB); err == nil {
+		t.Fatalf("classic row should not parse as alb")
+	}
+}
+
+func TestClassicField(t *testing.T) {
+	e, err := Parse([]byte(testClassicRow))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tt := []struct {
+		name string
+		out  string
+		ok   bool
+	}{
+		{"timestamp", "2015-05-13T23:39:43.945958Z", true},
+		{"time", "2015-05-13T23:39:43.945958Z", true},
+		{"backend:port", "10.0.0.1:80", true},
+		{"target:port", "10.0.0.1:80", true},
+		{"targetStatus_code", "200", true},
+		{"type", "", false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := e.Field(tc.name)
+			if ok != tc.ok || got != tc.out {
+				t.Fatalf("field %s should be %q (%v); got %q (%v)", tc.name, tc.out, tc.ok, got, ok)
+			}
+		})
+	}
+}
+
+func TestClassicCat(t *testing.T) {
+	tt := []struct {
+		name   string
+		filter Filter
+		out    int
+	}{
+		{"no-filter", Filter{}, 2},
+		{"client-ip", Filter{ClientIP: []string{"192.168.131.39"}}, 1},
+		{"target-status-code", Filter{TargetStatusCode: []string{"5.*"}}, 1},
+		{"http-method", Filter{HTTPmethod: []string{"POST"}}, 1},
+	}
+	rows := testClassicRow + "\n" +
+		`2015-05-13T23:39:44.000000Z my-loadbalancer 192.168.131.40:2818 10.0.0.1:80 0.000073 0.001048 0.000057 502 502 0 29 "POST http://www.example.com:80/ HTTP/1.1" "curl/7.38.0" - -` + "\n"
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			buff := &bytes.Buffer{}
+			gw := gzip.NewWriter(buff)
+			gw.Write([]byte(rows))
+			gw.Close()
+			a := Accesslog{Content: buff, RowFilter: tc.filter}
+			entries, err := a.Entries()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := len(entries); got != tc.out {
+				t.Fatalf("expected %d rows; got %d", tc.out, got)
+			}
+		})
+	}
+}
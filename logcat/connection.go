@@ -0,0 +1,77 @@
+package logcat
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	connectionMinFields = 11
+)
+
+var (
+	// connectionFields is the field names of the alb connection log row in the order they are written
+	connectionFields = []string{
+		"timestamp",
+		"client_ip",
+		"client_port",
+		"listener_port",
+		"tls_protocol",
+		"tls_cipher",
+		"tls_handshake_latency",
+		"leaf_client_cert_subject",
+		"leaf_client_cert_validity",
+		"leaf_client_cert_serial_number",
+		"tls_verify_status",
+		"conn_trace_id",
+	}
+	// connectionAliases maps the accesslog names to the connection log fields
+	connectionAliases = map[string]string{
+		"time":         "timestamp",
+		"ssl_protocol": "tls_protocol",
+		"ssl_cipher":   "tls_cipher",
+	}
+)
+
+func parseConnection(p *fieldParser, e *Entry) {
+	e.Time = p.time(0)
+	e.ClientIP = p.str(1)
+	e.ClientPort = p.int(2)
+	e.ListenerPort = p.int(3)
+	e.SSLProtocol = p.str(4)
+	e.SSLCipher = p.str(5)
+	e.TLSHandshakeLatency = p.float(6)
+	e.ClientCertSubject = p.str(7)
+	e.ClientCertNotBefore, e.ClientCertNotAfter = p.validity(8)
+	e.ClientCertSerial = p.str(9)
+	e.TLSVerifyStatus = p.str(10)
+	e.ConnTraceID = p.str(11)
+}
+
+// validity parses the leaf_client_cert_validity field that is written as
+// NotBefore=2023-09-21T22:43:21Z;NotAfter=2026-09-20T22:43:21Z
+func (p *fieldParser) validity(pos int) (time.Time, time.Time) {
+	var notBefore, notAfter time.Time
+	s := p.str(pos)
+	if s == "" || s == "-" {
+		return notBefore, notAfter
+	}
+	for _, part := range strings.Split(s, ";") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339Nano, kv[1])
+		if err != nil {
+			p.fail(pos, "certificate validity", err)
+			continue
+		}
+		switch kv[0] {
+		case "NotBefore":
+			notBefore = t
+		case "NotAfter":
+			notAfter = t
+		}
+	}
+	return notBefore, notAfter
+}
@@ -0,0 +1,51 @@
+package logcat
+
+import (
+	"testing"
+	"time"
+)
+
+const (
+	testConnectionRow = `2023-10-04T17:25:55.112466Z 203.0.113.5 37262 443 TLSv1.2 ECDHE-RSA-AES128-GCM-SHA256 0.004 "CN=client.example.com,O=Example Corp" NotBefore=2023-09-21T22:43:21Z;NotAfter=2026-09-20T22:43:21Z FEF257D4B39C2A61 Success TID_1234abcd5678ef90`
+)
+
+func TestParseConnection(t *testing.T) {
+	e, err := Parse([]byte(testConnectionRow))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e.Format != FormatConnection {
+		t.Fatalf("format: got %v", e.Format)
+	}
+	if e.ClientIP != "203.0.113.5" || e.ClientPort != 37262 || e.ListenerPort != 443 {
+		t.Errorf("client/listener: got %s:%d/%d", e.ClientIP, e.ClientPort, e.ListenerPort)
+	}
+	if e.TLSHandshakeLatency != 0.004 || e.SSLProtocol != "TLSv1.2" {
+		t.Errorf("tls_handshake_latency/tls_protocol: got %v/%q", e.TLSHandshakeLatency, e.SSLProtocol)
+	}
+	if e.ClientCertSubject != "CN=client.example.com,O=Example Corp" || e.ClientCertSerial != "FEF257D4B39C2A61" {
+		t.Errorf("cert subject/serial: got %q/%q", e.ClientCertSubject, e.ClientCertSerial)
+	}
+	if want := time.Date(2026, 9, 20, 22, 43, 21, 0, time.UTC); !e.ClientCertNotAfter.Equal(want) {
+		t.Errorf("cert not after: got %v, want %v", e.ClientCertNotAfter, want)
+	}
+	if e.ClientCertNotBefore.IsZero() {
+		t.Errorf("cert not before not parsed")
+	}
+	if e.TLSVerifyStatus != "Success" || e.ConnTraceID != "TID_1234abcd5678ef90" {
+		t.Errorf("tls_verify_status/conn_trace_id: got %q/%q", e.TLSVerifyStatus, e.ConnTraceID)
+	}
+	if got, _ := e.Field("time"); got != "2023-10-04T17:25:55.112466Z" {
+		t.Errorf("field time: got %q", got)
+	}
+}
+
+func TestParseConnectionWithoutCert(t *testing.T) {
+	e, err := ParseFormat([]byte(`2023-10-04T17:25:55.112466Z 203.0.113.5 37262 443 TLSv1.2 ECDHE-RSA-AES128-GCM-SHA256 0.004 - - - Failed:UnmappedConnectionError`), FormatConnection)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !e.ClientCertNotAfter.IsZero() || e.TLSVerifyStatus != "Failed:UnmappedConnectionError" || e.ConnTraceID != "" {
+		t.Fatalf("cert not after/tls_verify_status/conn_trace_id: got %v/%q/%q", e.ClientCertNotAfter, e.TLSVerifyStatus, e.ConnTraceID)
+	}
+}
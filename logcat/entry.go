@@ -0,0 +1,393 @@
+package logcat
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	// Schema is the version of the log layout, see ALBSchemaBase and friends
+	Schema int
+
+	// Entry is one parsed row of an accesslog
+	Entry struct {
+		Format                 Format
+		Schema                 Schema
+		Type                   string
+		Time                   time.Time
+		ELB                    string
+		ClientIP               string
+		ClientPort             int
+		TargetIP               string
+		TargetPort             int
+		RequestProcessingTime  float64
+		TargetProcessingTime   float64
+		ResponseProcessingTime float64
+		ELBStatusCode          int
+		TargetStatusCode       int
+		ReceivedBytes          int64
+		SentBytes              int64
+		Request                string
+		RequestMethod          string
+		RequestURL             string
+		RequestProtocol        string
+		UserAgent              string
+		SSLCipher              string
+		SSLProtocol            string
+		TargetGroupARN         string
+		TraceID                string
+		DomainName             string
+		ChosenCertARN          string
+		MatchedRulePriority    string
+		RequestCreationTime    time.Time
+		ActionsExecuted        string
+		RedirectURL            string
+		ErrorReason            string
+		TargetPortList         []string
+		TargetStatusCodeList   []int
+		Classification         string
+		ClassificationReason   string
+		ConnTraceID            string
+		// Network load balancer tls fields
+		Version                   string
+		Listener                  string
+		ConnectionTime            time.Duration
+		TLSHandshakeTime          time.Duration
+		IncomingTLSAlert          string
+		ChosenCertSerial          string
+		TLSNamedGroup             string
+		ALPNFrontendProtocol      string
+		ALPNBackendProtocol       string
+		ALPNClientPreferenceList  []string
+		TLSConnectionCreationTime time.Time
+		// Connection log fields
+		ListenerPort        int
+		TLSHandshakeLatency float64
+		ClientCertSubject   string
+		ClientCertNotBefore time.Time
+		ClientCertNotAfter  time.Time
+		ClientCertSerial    string
+		TLSVerifyStatus     string
+		// ExtraFields holds trailing fields newer than ALBSchemaLatest
+		ExtraFields []string
+		// AwsAccountID and Region is the account and region the log was written
+		// for, they are not part of the row and are set by Accesslog
+		AwsAccountID string
+		Region       string
+
+		fields []string
+	}
+)
+
+// Parse parses one accesslog row into an Entry, the log format is detected from the row
+func Parse(line []byte) (Entry, error) {
+	return ParseFormat(line, FormatAuto)
+}
+
+// ParseFormat parses one row written in format into an Entry
+func ParseFormat(line []byte, format Format) (Entry, error) {
+	fields, err := splitFields(string(line))
+	if err != nil {
+		return Entry{}, fmt.Errorf("%w: %v", ErrBadRow, err)
+	}
+	if format == FormatAuto {
+		format = detectFormat(fields)
+	}
+	spec, ok := formats[format]
+	if !ok {
+		return Entry{}, fmt.Errorf("%w: unknown log format: %v", ErrBadRow, format)
+	}
+	if len(fields) < spec.minFields {
+		return Entry{}, fmt.Errorf("%w: %s: expected at least %d fields, got %d", ErrBadRow, spec.name, spec.minFields, len(fields))
+	}
+
+	e := Entry{Format: format, fields: fields}
+	p := fieldParser{fields: fields}
+	spec.parse(&p, &e)
+	if p.err != nil {
+		return Entry{}, fmt.Errorf("%w: %s: %v", ErrBadRow, spec.name, p.err)
+	}
+	return e, nil
+}
+
+// Field return the raw value of the field with name as it was written in the accesslog
+func (e *Entry) Field(name string) (string, bool) {
+	spec := formats[e.Format]
+	if alias, ok := fieldAliases[name]; ok {
+		name = alias
+	}
+	switch name {
+	case "aws_account_id":
+		return e.AwsAccountID, e.AwsAccountID != ""
+	case "region":
+		return e.Region, e.Region != ""
+	case "request.method", "request.url", "request.protocol", "request.host", "request.path", "request.query":
+		return e.requestPart(name)
+	}
+	if alias, ok := spec.aliases[name]; ok {
+		name = alias
+	}
+	pos, ok := spec.positions[name]
+	if !ok || pos >= len(e.fields) {
+		return "", false
+	}
+	return e.fields[pos], true
+}
+
+// requestPart return the part of the request with name, like request.path
+func (e *Entry) requestPart(name string) (string, bool) {
+	if e.Request == "" {
+		return "", false
+	}
+	switch name {
+	case "request.method":
+		return e.RequestMethod, true
+	case "request.url":
+		return e.RequestURL, true
+	case "request.protocol":
+		return e.RequestProtocol, true
+	}
+	u, ok := e.requestURL()
+	if !ok {
+		return "-", true
+	}
+	switch name {
+	case "request.host":
+		return u.Hostname(), true
+	case "request.path":
+		return u.Path, true
+	}
+	return u.RawQuery, true
+}
+
+// requestURL return the parsed url of the request, false for the "- - - " of
+// rejected connections and urls that can not be parsed
+func (e *Entry) requestURL() (*url.URL, bool) {
+	if e.RequestURL == "" || e.RequestURL == "-" {
+		return nil, false
+	}
+	u, err := url.Parse(e.RequestURL)
+	return u, err == nil
+}
+
+// isField return true if name is a field of any format
+func isField(name string) bool {
+	if alias, ok := fieldAliases[name]; ok {
+		name = alias
+	}
+	switch name {
+	case "aws_account_id", "region", "request.method", "request.url", "request.protocol", "request.host", "request.path", "request.query":
+		return true
+	}
+	for _, spec := range formats {
+		if _, ok := spec.positions[name]; ok {
+			return true
+		}
+		if _, ok := spec.aliases[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func positions(names []string) map[string]int {
+	m := make(map[string]int, len(names))
+	for i, name := range names {
+		m[name] = i
+	}
+	return m
+}
+
+// fieldParser converts the raw fields and keeps the first error it ran into
+type fieldParser struct {
+	fields []string
+	err    error
+}
+
+func (p *fieldParser) fail(pos int, kind string, err error) {
+	if p.err == nil {
+		p.err = fmt.Errorf("field %d (%q) is not a valid %s: %v", pos, p.fields[pos], kind, err)
+	}
+}
+
+// str return the field at pos, fields missing in older schemas are returned as empty
+func (p *fieldParser) str(pos int) string {
+	if pos >= len(p.fields) {
+		return ""
+	}
+	return p.fields[pos]
+}
+
+func (p *fieldParser) list(pos int) []string {
+	if s := p.str(pos); s != "" && s != "-" {
+		return strings.Fields(s)
+	}
+	return nil
+}
+
+// quotedList parses a comma separated list of quoted strings like "h2","http/1.1"
+func (p *fieldParser) quotedList(pos int) []string {
+	s := p.str(pos)
+	if s == "" || s == "-" {
+		return nil
+	}
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		list = append(list, strings.Trim(v, `"`))
+	}
+	return list
+}
+
+func (p *fieldParser) intList(pos int) []int {
+	var codes []int
+	for _, v := range p.list(pos) {
+		if v == "-" {
+			codes = append(codes, 0)
+			continue
+		}
+		i, err := strconv.Atoi(v)
+		if err != nil {
+			p.fail(pos, "integer list", err)
+		}
+		codes = append(codes, i)
+	}
+	return codes
+}
+
+func (p *fieldParser) time(pos int) time.Time {
+	if p.str(pos) == "" || p.fields[pos] == "-" {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339Nano, p.fields[pos])
+	if err != nil {
+		// network load balancer timestamps are written in UTC without zone
+		if t, nerr := time.Parse(nlbTimeFormat, p.fields[pos]); nerr == nil {
+			return t
+		}
+		p.fail(pos, "timestamp", err)
+	}
+	return t
+}
+
+// millis parses a field in milliseconds, "-" is returned as -1
+func (p *fieldParser) millis(pos int) time.Duration {
+	if p.str(pos) == "" || p.fields[pos] == "-" {
+		return -1
+	}
+	ms, err := strconv.ParseInt(p.fields[pos], 10, 64)
+	if err != nil {
+		p.fail(pos, "integer", err)
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+func (p *fieldParser) float(pos int) float64 {
+	if p.str(pos) == "" || p.fields[pos] == "-" {
+		return -1
+	}
+	f, err := strconv.ParseFloat(p.fields[pos], 64)
+	if err != nil {
+		p.fail(pos, "number", err)
+	}
+	return f
+}
+
+func (p *fieldParser) int(pos int) int {
+	if p.str(pos) == "" || p.fields[pos] == "-" {
+		return 0
+	}
+	i, err := strconv.Atoi(p.fields[pos])
+	if err != nil {
+		p.fail(pos, "integer", err)
+	}
+	return i
+}
+
+func (p *fieldParser) int64(pos int) int64 {
+	if p.str(pos) == "" || p.fields[pos] == "-" {
+		return 0
+	}
+	i, err := strconv.ParseInt(p.fields[pos], 10, 64)
+	if err != nil {
+		p.fail(pos, "integer", err)
+	}
+	return i
+}
+
+func (p *fieldParser) hostPort(pos int) (string, int) {
+	if p.str(pos) == "" || p.fields[pos] == "-" {
+		return "", 0
+	}
+	host, port, err := splitHostPort(p.fields[pos])
+	if err != nil {
+		p.fail(pos, "host:port", err)
+	}
+	return host, port
+}
+
+// splitHostPort splits ip:port. IPv6 addresses are written without brackets
+// in the accesslog so the port is whatever comes after the last colon.
+func splitHostPort(s string) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(s)
+	if err != nil {
+		i := strings.LastIndex(s, ":")
+		if i < 0 {
+			return "", 0, err
+		}
+		host, portStr = s[:i], s[i+1:]
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return "", 0, err
+	}
+	return host, port, nil
+}
+
+// splitRequest splits the request field into method, url and protocol
+func splitRequest(request string) (string, string, string) {
+	parts := strings.SplitN(request, " ", 3)
+	for len(parts) < 3 {
+		parts = append(parts, "")
+	}
+	return parts[0], parts[1], strings.TrimSpace(parts[2])
+}
+
+// splitFields splits a row on spaces. Spaces inside double quotes do not
+// split the field and a field that is one quoted string is returned without
+// the quotes.
+func splitFields(line string) ([]string, error) {
+	var fields []string
+	i := 0
+	for i < len(line) {
+		if line[i] == ' ' {
+			i++
+			continue
+		}
+		start, quoted, quotes := i, false, 0
+		for ; i < len(line) && (quoted || line[i] != ' '); i++ {
+			switch {
+			case line[i] == '\\' && quoted:
+				i++
+			case line[i] == '"':
+				quoted = !quoted
+				quotes++
+			}
+		}
+		if quoted {
+			return nil, fmt.Errorf("unterminated quoted field at column %d", start+1)
+		}
+		if i > len(line) {
+			i = len(line)
+		}
+		field := line[start:i]
+		if quotes == 2 && len(field) >= 2 && field[0] == '"' && field[len(field)-1] == '"' {
+			field = field[1 : len(field)-1]
+		}
+		fields = append(fields, field)
+	}
+	return fields, nil
+}
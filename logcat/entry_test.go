@@ -0,0 +1,189 @@
+package logcat
+
+import (
+	"testing"
+	"time"
+)
+
+const (
+	testALBRow         = `https 2019-02-02T00:14:07.437021Z elb01 10.222.161.42:32774 10.222.20.10:443 0.000 0.002 0.000 200 201 371 178 "GET https://elb01.prod.com:443/status?a=b HTTP/1.1" "Faraday v0.9.2" ECDHE-RSA-AES128-GCM-SHA256 TLSv1.2 arn:aws:elasticloadbalancing:eu-west-1:0123456789:targetgroup/prod-tg/8f858d88ba9c836c "Root=1-xxxxxx-yyyyyyyyyyyyyyyyyyyyy" "elb01.prod.com" "arn:aws:acm:eu-west-1:0123456789:certificate/bbbbbbbb-1cbf-4f99-aaaa-cccccccccccc" 0 2019-02-02T00:14:07.435000Z "forward" "-" "-"`
+	testALBLatestRow   = `h2 2024-05-20T10:00:00.123456Z app/elb02/50dc6c495c0c9188 192.168.131.39:2817 10.0.0.1:80 0.000 0.001 0.000 502 502 34 366 "GET https://www.example.com:443/ HTTP/2.0" "curl/7.46.0" ECDHE-RSA-AES128-GCM-SHA256 TLSv1.2 arn:aws:elasticloadbalancing:us-east-2:123456789012:targetgroup/my-targets/73e2d6bc24d8a067 "Root=1-58337281-1d84f3d73c47ec4e58577259" "www.example.com" "arn:aws:acm:us-east-2:123456789012:certificate/12345678-1234-1234-1234-123456789012" 1 2024-05-20T09:59:59.999000Z "forward" "-" "-" "10.0.0.1:80 10.0.0.2:80" "502 -" "Ambiguous" "UndefinedContentLengthSemantics" TID_1234abcd5678ef90`
+	testALBRejectedRow = `http 2019-02-02T00:14:08.000000Z elb01 2001:db8::1:51234 - -1 -1 -1 400 - 0 272 "- - - " "-" - - - "-" "-" "-" - 2019-02-02T00:14:08.000000Z "-" "-" "-"`
+)
+
+func TestParse(t *testing.T) {
+	tt := []struct {
+		name  string
+		in    string
+		check func(t *testing.T, e Entry)
+	}{
+		{
+			"alb-row",
+			testALBRow,
+			func(t *testing.T, e Entry) {
+				want := time.Date(2019, 2, 2, 0, 14, 7, 437021000, time.UTC)
+				if !e.Time.Equal(want) {
+					t.Errorf("time: got %v, want %v", e.Time, want)
+				}
+				if e.Type != "https" || e.ELB != "elb01" {
+					t.Errorf("type/elb: got %q/%q", e.Type, e.ELB)
+				}
+				if e.ClientIP != "10.222.161.42" || e.ClientPort != 32774 {
+					t.Errorf("client: got %s:%d", e.ClientIP, e.ClientPort)
+				}
+				if e.TargetIP != "10.222.20.10" || e.TargetPort != 443 {
+					t.Errorf("target: got %s:%d", e.TargetIP, e.TargetPort)
+				}
+				if e.TargetProcessingTime != 0.002 {
+					t.Errorf("target_processing_time: got %v", e.TargetProcessingTime)
+				}
+				if e.ELBStatusCode != 200 || e.TargetStatusCode != 201 {
+					t.Errorf("status codes: got %d/%d", e.ELBStatusCode, e.TargetStatusCode)
+				}
+				if e.ReceivedBytes != 371 || e.SentBytes != 178 {
+					t.Errorf("bytes: got %d/%d", e.ReceivedBytes, e.SentBytes)
+				}
+				if e.RequestMethod != "GET" || e.RequestURL != "https://elb01.prod.com:443/status?a=b" || e.RequestProtocol != "HTTP/1.1" {
+					t.Errorf("request: got %q %q %q", e.RequestMethod, e.RequestURL, e.RequestProtocol)
+				}
+				if e.UserAgent != "Faraday v0.9.2" {
+					t.Errorf("user_agent: got %q", e.UserAgent)
+				}
+				if e.DomainName != "elb01.prod.com" || e.ActionsExecuted != "forward" {
+					t.Errorf("domain_name/actions_executed: got %q/%q", e.DomainName, e.ActionsExecuted)
+				}
+				if e.RequestCreationTime.IsZero() {
+					t.Errorf("request_creation_time not parsed")
+				}
+			},
+		},
+		{
+			"rejected-connection",
+			testALBRejectedRow,
+			func(t *testing.T, e Entry) {
+				if e.ClientIP != "2001:db8::1" || e.ClientPort != 51234 {
+					t.Errorf("client: got %s:%d", e.ClientIP, e.ClientPort)
+				}
+				if e.TargetIP != "" || e.TargetPort != 0 {
+					t.Errorf("target: got %s:%d", e.TargetIP, e.TargetPort)
+				}
+				if e.RequestProcessingTime != -1 || e.TargetStatusCode != 0 {
+					t.Errorf("request_processing_time/target_status_code: got %v/%d", e.RequestProcessingTime, e.TargetStatusCode)
+				}
+				if e.RequestMethod != "-" || e.RequestURL != "-" || e.RequestProtocol != "-" {
+					t.Errorf("request: got %q %q %q", e.RequestMethod, e.RequestURL, e.RequestProtocol)
+				}
+			},
+		},
+		{
+			"latest-schema",
+			testALBLatestRow,
+			func(t *testing.T, e Entry) {
+				if e.Schema != ALBSchemaLatest {
+					t.Errorf("schema: got %d, want %d", e.Schema, ALBSchemaLatest)
+				}
+				if len(e.TargetPortList) != 2 || e.TargetPortList[1] != "10.0.0.2:80" {
+					t.Errorf("target:port_list: got %v", e.TargetPortList)
+				}
+				if len(e.TargetStatusCodeList) != 2 || e.TargetStatusCodeList[0] != 502 {
+					t.Errorf("target_status_code_list: got %v", e.TargetStatusCodeList)
+				}
+				if e.Classification != "Ambiguous" || e.ClassificationReason != "UndefinedContentLengthSemantics" {
+					t.Errorf("classification: got %q/%q", e.Classification, e.ClassificationReason)
+				}
+				if e.ConnTraceID != "TID_1234abcd5678ef90" {
+					t.Errorf("conn_trace_id: got %q", e.ConnTraceID)
+				}
+				if e.ExtraFields != nil {
+					t.Errorf("extra fields: got %v", e.ExtraFields)
+				}
+			},
+		},
+		{
+			"future-schema",
+			testALBLatestRow + ` "new-field" 42`,
+			func(t *testing.T, e Entry) {
+				if e.Schema != ALBSchemaLatest || e.ConnTraceID != "TID_1234abcd5678ef90" {
+					t.Errorf("schema/conn_trace_id: got %d/%q", e.Schema, e.ConnTraceID)
+				}
+				if len(e.ExtraFields) != 2 || e.ExtraFields[0] != "new-field" {
+					t.Errorf("extra fields: got %v", e.ExtraFields)
+				}
+			},
+		},
+		{
+			"base-schema",
+			`https 2017-02-02T00:14:07.437021Z elb01 10.222.161.42:32774 10.222.20.10:443 0.000 0.002 0.000 200 200 371 178 "GET https://elb01.prod.com:443/status HTTP/1.1" "Faraday v0.9.2" ECDHE-RSA-AES128-GCM-SHA256 TLSv1.2 arn:aws:elasticloadbalancing:eu-west-1:0123456789:targetgroup/prod-tg/8f858d88ba9c836c "Root=1-xxxxxx-yyyyyyyyyyyyyyyyyyyyy"`,
+			func(t *testing.T, e Entry) {
+				if e.Schema != ALBSchemaBase {
+					t.Errorf("schema: got %d, want %d", e.Schema, ALBSchemaBase)
+				}
+				if e.TraceID != "Root=1-xxxxxx-yyyyyyyyyyyyyyyyyyyyy" || e.DomainName != "" || !e.RequestCreationTime.IsZero() {
+					t.Errorf("trace_id/domain_name/request_creation_time: got %q/%q/%v", e.TraceID, e.DomainName, e.RequestCreationTime)
+				}
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			e, err := Parse([]byte(tc.in))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			tc.check(t, e)
+		})
+	}
+}
+
+func TestParseError(t *testing.T) {
+	tt := []struct {
+		name string
+		in   string
+	}{
+		{"empty", ""},
+		{"too-few-fields", `https 2019-02-02T00:14:07.437021Z elb01 10.222.161.42:32774`},
+		{"unterminated-quote", `https 2019-02-02T00:14:07.437021Z elb01 10.222.161.42:32774 10.222.20.10:443 0.000 0.002 0.000 200 200 371 178 "GET https://elb01.prod.com:443/status HTTP/1.1`},
+		{"bad-timestamp", `https 2019-02-02 elb01 10.222.161.42:32774 10.222.20.10:443 0.000 0.002 0.000 200 200 371 178 "GET / HTTP/1.1" "-" - - - "-" "-" "-" 0 2019-02-02T00:14:07.435000Z "forward" "-" "-"`},
+		{"bad-status-code", `https 2019-02-02T00:14:07.437021Z elb01 10.222.161.42:32774 10.222.20.10:443 0.000 0.002 0.000 2xx 200 371 178 "GET / HTTP/1.1" "-" - - - "-" "-" "-" 0 2019-02-02T00:14:07.435000Z "forward" "-" "-"`},
+		{"bad-client", `https 2019-02-02T00:14:07.437021Z elb01 10.222.161.42 10.222.20.10:443 0.000 0.002 0.000 200 200 371 178 "GET / HTTP/1.1" "-" - - - "-" "-" "-" 0 2019-02-02T00:14:07.435000Z "forward" "-" "-"`},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tc.in)); err == nil {
+				t.Fatalf("expected error parsing %q", tc.in)
+			}
+		})
+	}
+}
+
+func TestEntryField(t *testing.T) {
+	e, err := Parse([]byte(testALBRow))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tt := []struct {
+		name string
+		out  string
+	}{
+		{"type", "https"},
+		{"client:port", "10.222.161.42:32774"},
+		{"request", "GET https://elb01.prod.com:443/status?a=b HTTP/1.1"},
+		{"user-agent", "Faraday v0.9.2"},
+		{"user_agent", "Faraday v0.9.2"},
+		{"elbStatis_code", "200"},
+		{"error_reason", "-"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, ok := e.Field(tc.name)
+			if !ok || got != tc.out {
+				t.Fatalf("field %s should be %q; got %q", tc.name, tc.out, got)
+			}
+		})
+	}
+	if _, ok := e.Field("no-such-field"); ok {
+		t.Fatalf("unknown field should not be found")
+	}
+}
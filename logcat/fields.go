@@ -0,0 +1,48 @@
+package logcat
+
+// fieldMatch is the patterns of a field of the row
+type fieldMatch struct {
+	field string
+	*patternMatch
+}
+
+// newFieldMatches compiles the patterns of the fields that are matched on
+// their raw value, the format anchors the patterns to the parts of the value
+// that should match.
+func newFieldMatches(filter Filter) ([]fieldMatch, error) {
+	var matches []fieldMatch
+	for _, f := range []struct {
+		name             string
+		field            string
+		format           string
+		include, exclude []string
+	}{
+		{"user-agent", "user_agent", "%s", filter.UserAgent, filter.ExcludeUserAgent},
+		{"domain-name", "domain_name", "(?i)^(?:%s)$", filter.DomainName, filter.ExcludeDomainName},
+		{"target-group", "target_group_arn", "^(?:arn:[^/]*:targetgroup/)?(?:%s)(?:/[0-9a-f]+)?$", filter.TargetGroup, filter.ExcludeTargetGroup},
+		{"trace-id", "trace_id", "(?:^|[=;])(?:%s)(?:;|$)", filter.TraceID, filter.ExcludeTraceID},
+		{"actions-executed", "actions_executed", "(?:^|,)(?:%s)(?:,|$)", filter.ActionsExecuted, filter.ExcludeActionsExecuted},
+		{"error-reason", "error_reason", "^(?:%s)$", filter.ErrorReason, filter.ExcludeErrorReason},
+	} {
+		m, err := compilePatterns(f.name, f.format, f.include, f.exclude)
+		if err != nil {
+			return nil, err
+		}
+		if m != nil {
+			matches = append(matches, fieldMatch{field: f.field, patternMatch: m})
+		}
+	}
+	return matches, nil
+}
+
+// matchFields return true if the fields of e match their patterns. Rows
+// without the field, like classic elb rows without trace_id, only match
+// exclude patterns.
+func (r *rowMatch) matchFields(e *Entry) bool {
+	for _, m := range r.fields {
+		if !m.matchValue(e.Field(m.field)) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,61 @@
+package logcat
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCatFields(t *testing.T) {
+	lambdaRow := strings.NewReplacer(
+		`"Faraday v0.9.2"`, `"ELB-HealthChecker/2.0"`,
+		`"elb01.prod.com"`, `"API.prod.com"`,
+		"targetgroup/prod-tg/8f858d88ba9c836c", "targetgroup/lambda-tg/1d84f3d73c47ec4e",
+		`"Root=1-xxxxxx-yyyyyyyyyyyyyyyyyyyyy"`, `"Self=1-67891233-12456789abcdef012345678;Root=1-67891233-abcdef012345678912345678"`,
+		`"forward" "-" "-"`, `"waf,lambda" "-" "LambdaUnhandled"`,
+	).Replace(testALBRow)
+	rows := map[string]string{
+		"alb":      testALBRow,
+		"lambda":   lambdaRow,
+		"rejected": testALBRejectedRow,
+		"classic":  testClassicRow,
+	}
+	tt := []struct {
+		name   string
+		filter Filter
+		out    []string
+	}{
+		{"user-agent", Filter{UserAgent: []string{"Faraday"}}, []string{"alb"}},
+		{"user-agent-any", Filter{UserAgent: []string{"^curl/", "HealthChecker"}}, []string{"classic", "lambda"}},
+		{"exclude-user-agent", Filter{ExcludeUserAgent: []string{"HealthChecker", "^-$"}}, []string{"alb", "classic"}},
+		{"domain-name", Filter{DomainName: []string{"api.prod.com"}}, []string{"lambda"}},
+		{"domain-name-whole", Filter{DomainName: []string{"prod.com"}}, nil},
+		{"exclude-domain-name", Filter{ExcludeDomainName: []string{`.*\.prod\.com`}}, []string{"classic", "rejected"}},
+		{"target-group-name", Filter{TargetGroup: []string{"prod-tg"}}, []string{"alb"}},
+		{"target-group-name-regexp", Filter{TargetGroup: []string{".*-tg"}}, []string{"alb", "lambda"}},
+		{"target-group-arn", Filter{TargetGroup: []string{"arn:aws:elasticloadbalancing:eu-west-1:0123456789:targetgroup/lambda-tg/1d84f3d73c47ec4e"}}, []string{"lambda"}},
+		{"target-group-part-of-name", Filter{TargetGroup: []string{"prod"}}, nil},
+		{"domain-and-target-group", Filter{DomainName: []string{"elb01.prod.com"}, TargetGroup: []string{"lambda-tg"}}, nil},
+		{"trace-id-root", Filter{TraceID: []string{"1-67891233-abcdef012345678912345678"}}, []string{"lambda"}},
+		{"trace-id-part", Filter{TraceID: []string{"Root=1-xxxxxx-yyyyyyyyyyyyyyyyyyyyy"}}, []string{"alb"}},
+		{"trace-id-prefix", Filter{TraceID: []string{"1-67891233"}}, nil},
+		{"actions-executed", Filter{ActionsExecuted: []string{"lambda"}}, []string{"lambda"}},
+		{"actions-executed-any", Filter{ActionsExecuted: []string{"forward", "waf"}}, []string{"alb", "lambda"}},
+		{"exclude-actions-executed", Filter{ExcludeActionsExecuted: []string{"waf"}}, []string{"alb", "classic", "rejected"}},
+		{"error-reason", Filter{ErrorReason: []string{"LambdaUnhandled"}}, []string{"lambda"}},
+		{"error-reason-lambda", Filter{ErrorReason: []string{"Lambda.*"}}, []string{"lambda"}},
+		{"exclude-error-reason", Filter{ExcludeErrorReason: []string{"-"}}, []string{"classic", "lambda"}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := matchingRows(t, rows, tc.filter); !reflect.DeepEqual(got, tc.out) {
+				t.Fatalf("should match %v; got %v", tc.out, got)
+			}
+		})
+	}
+
+	if err := (Filter{ExcludeTraceID: []string{"(Root"}}).Validate(); !errors.Is(err, ErrBadFilter) {
+		t.Fatalf("bad trace id pattern should fail with ErrBadFilter; got %v", err)
+	}
+}
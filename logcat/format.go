@@ -0,0 +1,117 @@
+package logcat
+
+import (
+	"fmt"
+	"time"
+)
+
+type (
+	// Format is the layout of the rows in a log
+	Format int
+
+	formatSpec struct {
+		name      string
+		minFields int
+		positions map[string]int
+		// aliases maps field names of other formats to the ones of this format
+		aliases map[string]string
+		parse   func(p *fieldParser, e *Entry)
+	}
+)
+
+const (
+	// FormatAuto detect the format for each row
+	FormatAuto Format = iota
+	// FormatALB is the application load balancer accesslog
+	FormatALB
+	// FormatClassic is the classic elb accesslog
+	FormatClassic
+	// FormatNLB is the network load balancer tls accesslog
+	FormatNLB
+	// FormatConnection is the alb connection log
+	FormatConnection
+)
+
+var (
+	formats = map[Format]formatSpec{
+		FormatALB: {
+			name:      "alb",
+			minFields: int(ALBSchemaBase),
+			positions: positions(albFields),
+			aliases:   albAliases,
+			parse:     parseALB,
+		},
+		FormatClassic: {
+			name:      "classic",
+			minFields: classicMinFields,
+			positions: positions(classicFields),
+			aliases:   classicAliases,
+			parse:     parseClassic,
+		},
+		FormatNLB: {
+			name:      "nlb",
+			minFields: nlbMinFields,
+			positions: positions(nlbFields),
+			aliases:   nlbAliases,
+			parse:     parseNLB,
+		},
+		FormatConnection: {
+			name:      "connection",
+			minFields: connectionMinFields,
+			positions: positions(connectionFields),
+			aliases:   connectionAliases,
+			parse:     parseConnection,
+		},
+	}
+)
+
+func (f Format) String() string {
+	if f == FormatAuto {
+		return "auto"
+	}
+	if spec, ok := formats[f]; ok {
+		return spec.name
+	}
+	return fmt.Sprintf("Format(%d)", int(f))
+}
+
+// FormatByName return the Format with name, as used by the --format flag
+func FormatByName(name string) (Format, error) {
+	if name == "" || name == "auto" {
+		return FormatAuto, nil
+	}
+	for f, spec := range formats {
+		if spec.name == name {
+			return f, nil
+		}
+	}
+	return FormatAuto, fmt.Errorf("unknown log format: %q", name)
+}
+
+// detectFormat guess the format of a row. Classic elb and connection log rows
+// start with the timestamp while alb and nlb rows start with the connection type.
+// The connection log writes the client ip and port as separate fields.
+func detectFormat(fields []string) Format {
+	if len(fields) == 0 {
+		return FormatALB
+	}
+	if fields[0] == "tls" {
+		return FormatNLB
+	}
+	if _, err := time.Parse(time.RFC3339Nano, fields[0]); err == nil {
+		if len(fields) > 2 && isDigits(fields[2]) {
+			return FormatConnection
+		}
+		return FormatClassic
+	}
+	return FormatALB
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
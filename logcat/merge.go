@@ -0,0 +1,180 @@
+package logcat
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// SortKey is the field the entries are ordered by in a Merge
+type SortKey int
+
+const (
+	// SortNone keeps the entries in the order they are read
+	SortNone SortKey = iota
+	// SortTime orders the entries by the time the response was sent
+	SortTime
+	// SortRequestCreationTime orders the entries by the time the request was received
+	SortRequestCreationTime
+)
+
+var errMergeStopped = errors.New("merge stopped")
+
+// SortKeyByName return the SortKey with name, empty is SortNone
+func SortKeyByName(name string) (SortKey, error) {
+	switch name {
+	case "", "none":
+		return SortNone, nil
+	case "time", "timestamp":
+		return SortTime, nil
+	case "request_creation_time":
+		return SortRequestCreationTime, nil
+	}
+	return SortNone, fmt.Errorf("unknown sort key %q, should be none, timestamp or request_creation_time", name)
+}
+
+// of return the time of e to sort by. Rows without request_creation_time, like
+// classic elb rows, are sorted by their timestamp.
+func (k SortKey) of(e *Entry) time.Time {
+	if k == SortRequestCreationTime && !e.RequestCreationTime.IsZero() {
+		return e.RequestCreationTime
+	}
+	return e.Time
+}
+
+type (
+	// MergeSource is one log of a Merge. Each should call fn with the entries of
+	// the log, like Accesslog.Each. Start is the earliest time of the entries in
+	// the log, the log is not read until the merge has come that far so only
+	// the logs that overlap in time is read at the same time.
+	MergeSource struct {
+		Start time.Time
+		Each  func(fn func(e Entry) error) error
+	}
+
+	// Merge orders the entries of many logs by Key. The entries of one log may be
+	// out of order by at most Window, entries that are more out of order than that
+	// are passed on as soon as they are read.
+	Merge struct {
+		Key    SortKey
+		Window time.Duration
+	}
+
+	mergeItem struct {
+		entry Entry
+		t     time.Time
+		seq   int
+	}
+	mergeHeap []mergeItem
+
+	mergeStream struct {
+		entries   chan Entry
+		err       chan error
+		watermark time.Time
+	}
+)
+
+func (h mergeHeap) Len() int { return len(h) }
+func (h mergeHeap) Less(i, j int) bool {
+	if h[i].t.Equal(h[j].t) {
+		return h[i].seq < h[j].seq
+	}
+	return h[i].t.Before(h[j].t)
+}
+func (h mergeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *mergeHeap) Push(x interface{}) { *h = append(*h, x.(mergeItem)) }
+func (h *mergeHeap) Pop() interface{} {
+	old := *h
+	item := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return item
+}
+
+// Run calls fn with the entries of the sources in order. The sources must be
+// ordered by Start. Run stops at the first error of a source or fn.
+func (m *Merge) Run(sources []MergeSource, fn func(e Entry) error) error {
+	stop := make(chan struct{})
+	defer close(stop)
+
+	var (
+		pending mergeHeap
+		active  []*mergeStream
+		next    int
+		seq     int
+	)
+	for {
+		// open the logs that can hold entries before the oldest active log
+		for next < len(sources) && (len(active) == 0 || !sources[next].Start.Add(-m.Window).After(active[0].watermark)) {
+			active = append(active, m.open(sources[next], stop))
+			next++
+			m.sortStreams(active)
+		}
+		if len(active) == 0 {
+			break
+		}
+
+		// nothing older than the oldest watermark can be read from the logs
+		s := active[0]
+		for len(pending) > 0 && !pending[0].t.After(s.watermark) {
+			if err := fn(heap.Pop(&pending).(mergeItem).entry); err != nil {
+				return err
+			}
+		}
+
+		e, ok := <-s.entries
+		if !ok {
+			if err := <-s.err; err != nil {
+				return err
+			}
+			active = active[1:]
+			m.sortStreams(active)
+			continue
+		}
+		t := m.Key.of(&e)
+		heap.Push(&pending, mergeItem{entry: e, t: t, seq: seq})
+		seq++
+		if w := t.Add(-m.Window); w.After(s.watermark) {
+			s.watermark = w
+			m.sortStreams(active)
+		}
+	}
+	for len(pending) > 0 {
+		if err := fn(heap.Pop(&pending).(mergeItem).entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// open starts reading the entries of src in the background
+func (m *Merge) open(src MergeSource, stop <-chan struct{}) *mergeStream {
+	s := &mergeStream{
+		entries:   make(chan Entry, 64),
+		err:       make(chan error, 1),
+		watermark: src.Start.Add(-m.Window),
+	}
+	go func() {
+		err := src.Each(func(e Entry) error {
+			select {
+			case s.entries <- e:
+				return nil
+			case <-stop:
+				return errMergeStopped
+			}
+		})
+		close(s.entries)
+		s.err <- err
+	}()
+	return s
+}
+
+// sortStreams moves the stream with the oldest watermark first, it is the one
+// that holds back the merge
+func (m *Merge) sortStreams(active []*mergeStream) {
+	for i := 1; i < len(active); i++ {
+		if active[i].watermark.Before(active[0].watermark) {
+			active[0], active[i] = active[i], active[0]
+		}
+	}
+}
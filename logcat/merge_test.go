@@ -0,0 +1,92 @@
+package logcat
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func testMergeSource(start time.Time, opened *bool, offsets ...int) MergeSource {
+	return MergeSource{
+		Start: start,
+		Each: func(fn func(e Entry) error) error {
+			if opened != nil {
+				*opened = true
+			}
+			for _, offset := range offsets {
+				t := start.Add(time.Duration(offset) * time.Second)
+				if err := fn(Entry{Time: t, RequestCreationTime: t.Add(-time.Duration(offset%7) * time.Second)}); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func TestMerge(t *testing.T) {
+	start := time.Date(2019, 2, 2, 0, 0, 0, 0, time.UTC)
+	var lateOpened, openedBeforeFirst bool
+	sources := []MergeSource{
+		testMergeSource(start, nil, 1, 5, 3, 10, 200),
+		testMergeSource(start, nil, 2, 4, 4, 11, 150),
+		testMergeSource(start.Add(10*time.Minute), &lateOpened, 0, 2, 1),
+	}
+	m := Merge{Key: SortTime, Window: 30 * time.Second}
+	var got []int
+	err := m.Run(sources, func(e Entry) error {
+		if len(got) == 0 {
+			openedBeforeFirst = lateOpened
+		}
+		got = append(got, int(e.Time.Sub(start)/time.Second))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []int{1, 2, 3, 4, 4, 5, 10, 11, 150, 200, 600, 601, 602}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("merge should be %v; got %v", want, got)
+	}
+	if openedBeforeFirst {
+		t.Fatalf("the log that starts 10 minutes later should not be read before the first entry is passed on")
+	}
+}
+
+func TestMergeRequestCreationTime(t *testing.T) {
+	start := time.Date(2019, 2, 2, 0, 0, 0, 0, time.UTC)
+	m := Merge{Key: SortRequestCreationTime, Window: 10 * time.Second}
+	var got []time.Time
+	err := m.Run([]MergeSource{testMergeSource(start, nil, 6, 7, 8, 9)}, func(e Entry) error {
+		got = append(got, e.RequestCreationTime)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := 1; i < len(got); i++ {
+		if got[i].Before(got[i-1]) {
+			t.Fatalf("entries should be ordered by request_creation_time; got %v", got)
+		}
+	}
+}
+
+func TestMergeErrors(t *testing.T) {
+	start := time.Date(2019, 2, 2, 0, 0, 0, 0, time.UTC)
+	stop := errors.New("stop")
+	m := Merge{Key: SortTime, Window: time.Second}
+	sources := []MergeSource{testMergeSource(start, nil, 1, 2, 3), testMergeSource(start, nil, 1, 2, 3)}
+	if err := m.Run(sources, func(e Entry) error { return stop }); err != stop {
+		t.Fatalf("Run should return the error of fn; got %v", err)
+	}
+
+	bad := MergeSource{Start: start, Each: func(fn func(e Entry) error) error { return ErrBadRow }}
+	if err := m.Run([]MergeSource{bad}, func(e Entry) error { return nil }); !errors.Is(err, ErrBadRow) {
+		t.Fatalf("Run should return the error of the source; got %v", err)
+	}
+
+	if _, err := SortKeyByName("size"); err == nil {
+		t.Fatalf("SortKeyByName of unknown key should fail")
+	}
+}
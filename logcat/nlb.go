@@ -0,0 +1,66 @@
+package logcat
+
+const (
+	nlbMinFields  = 22
+	nlbTimeFormat = "2006-01-02T15:04:05"
+)
+
+var (
+	// nlbFields is the field names of the network load balancer tls row in the order they are written
+	nlbFields = []string{
+		"type",
+		"version",
+		"time",
+		"elb",
+		"listener",
+		"client:port",
+		"destination:port",
+		"connection_time",
+		"tls_handshake_time",
+		"received_bytes",
+		"sent_bytes",
+		"incoming_tls_alert",
+		"chosen_cert_arn",
+		"chosen_cert_serial",
+		"tls_cipher",
+		"tls_protocol_version",
+		"tls_named_group",
+		"domain_name",
+		"alpn_fe_protocol",
+		"alpn_be_protocol",
+		"alpn_client_preference_list",
+		"tls_connection_creation_time",
+	}
+	// nlbAliases maps the alb names to the network load balancer fields
+	nlbAliases = map[string]string{
+		"timestamp":    "time",
+		"target:port":  "destination:port",
+		"ssl_cipher":   "tls_cipher",
+		"ssl_protocol": "tls_protocol_version",
+	}
+)
+
+func parseNLB(p *fieldParser, e *Entry) {
+	e.Type = p.str(0)
+	e.Version = p.str(1)
+	e.Time = p.time(2)
+	e.ELB = p.str(3)
+	e.Listener = p.str(4)
+	e.ClientIP, e.ClientPort = p.hostPort(5)
+	e.TargetIP, e.TargetPort = p.hostPort(6)
+	e.ConnectionTime = p.millis(7)
+	e.TLSHandshakeTime = p.millis(8)
+	e.ReceivedBytes = p.int64(9)
+	e.SentBytes = p.int64(10)
+	e.IncomingTLSAlert = p.str(11)
+	e.ChosenCertARN = p.str(12)
+	e.ChosenCertSerial = p.str(13)
+	e.SSLCipher = p.str(14)
+	e.SSLProtocol = p.str(15)
+	e.TLSNamedGroup = p.str(16)
+	e.DomainName = p.str(17)
+	e.ALPNFrontendProtocol = p.str(18)
+	e.ALPNBackendProtocol = p.str(19)
+	e.ALPNClientPreferenceList = p.quotedList(20)
+	e.TLSConnectionCreationTime = p.time(21)
+}
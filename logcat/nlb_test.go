@@ -0,0 +1,89 @@
+package logcat
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+	"time"
+)
+
+const (
+	testNLBRow          = `tls 2.0 2018-12-20T02:59:40 net/my-network-loadbalancer/c6e77e28c25b2234 g3d4b5e8bb8464cd 72.21.218.154:51341 172.100.100.185:443 5 2 98 246 - arn:aws:acm:us-east-2:671290407336:certificate/2a108f19-aded-46b0-8493-c63eb1ef4a99 - ECDHE-RSA-AES128-SHA tlsv12 - my-network-loadbalancer-c6e77e28c25b2234.elb.us-east-2.amazonaws.com h2 h2 "h2","http/1.1" 2018-12-20T02:59:30`
+	testNLBHandshakeRow = `tls 2.0 2020-04-01T08:51:42 net/my-network-loadbalancer/c6e77e28c25b2234 g3d4b5e8bb8464cd 203.0.113.4:44222 172.100.100.185:443 4 - 0 0 UnknownCA arn:aws:acm:us-east-2:671290407336:certificate/2a108f19-aded-46b0-8493-c63eb1ef4a99 - - - - - - - - 2020-04-01T08:51:38`
+)
+
+func TestParseNLB(t *testing.T) {
+	e, err := Parse([]byte(testNLBRow))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e.Format != FormatNLB || e.Version != "2.0" || e.Listener != "g3d4b5e8bb8464cd" {
+		t.Errorf("format/version/listener: got %v/%q/%q", e.Format, e.Version, e.Listener)
+	}
+	if want := time.Date(2018, 12, 20, 2, 59, 40, 0, time.UTC); !e.Time.Equal(want) {
+		t.Errorf("time: got %v, want %v", e.Time, want)
+	}
+	if e.TargetIP != "172.100.100.185" || e.TargetPort != 443 {
+		t.Errorf("destination: got %s:%d", e.TargetIP, e.TargetPort)
+	}
+	if e.ConnectionTime != 5*time.Millisecond || e.TLSHandshakeTime != 2*time.Millisecond {
+		t.Errorf("connection_time/tls_handshake_time: got %v/%v", e.ConnectionTime, e.TLSHandshakeTime)
+	}
+	if e.SSLCipher != "ECDHE-RSA-AES128-SHA" || e.SSLProtocol != "tlsv12" {
+		t.Errorf("tls_cipher/tls_protocol_version: got %q/%q", e.SSLCipher, e.SSLProtocol)
+	}
+	if e.ALPNFrontendProtocol != "h2" || len(e.ALPNClientPreferenceList) != 2 || e.ALPNClientPreferenceList[1] != "http/1.1" {
+		t.Errorf("alpn: got %q/%v", e.ALPNFrontendProtocol, e.ALPNClientPreferenceList)
+	}
+	if e.TLSConnectionCreationTime.IsZero() {
+		t.Errorf("tls_connection_creation_time not parsed")
+	}
+	for name, want := range map[string]string{
+		"tls_handshake_time": "2",
+		"target:port":        "172.100.100.185:443",
+		"timestamp":          "2018-12-20T02:59:40",
+		"domain_name":        "my-network-loadbalancer-c6e77e28c25b2234.elb.us-east-2.amazonaws.com",
+	} {
+		if got, _ := e.Field(name); got != want {
+			t.Errorf("field %s should be %q; got %q", name, want, got)
+		}
+	}
+}
+
+func TestParseNLBFailedHandshake(t *testing.T) {
+	e, err := Parse([]byte(testNLBHandshakeRow))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if e.TLSHandshakeTime != -1 || e.IncomingTLSAlert != "UnknownCA" {
+		t.Fatalf("tls_handshake_time/incoming_tls_alert: got %v/%q", e.TLSHandshakeTime, e.IncomingTLSAlert)
+	}
+}
+
+func TestNLBCat(t *testing.T) {
+	tt := []struct {
+		name   string
+		filter Filter
+		out    int
+	}{
+		{"no-filter", Filter{ClientIP: []string{".*"}, ElbStatusCode: []string{".*"}, TargetStatusCode: []string{".*"}, HTTPmethod: []string{".*"}}, 2},
+		{"client-ip", Filter{ClientIP: []string{"203.0.113.4"}}, 1},
+		{"elb-status-code", Filter{ElbStatusCode: []string{"200"}}, 0},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			buff := &bytes.Buffer{}
+			gw := gzip.NewWriter(buff)
+			gw.Write([]byte(testNLBRow + "\n" + testNLBHandshakeRow + "\n"))
+			gw.Close()
+			a := Accesslog{Content: buff, RowFilter: tc.filter, PrintFields: "time client:port tls_handshake_time incoming_tls_alert"}
+			entries, err := a.Entries()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := len(entries); got != tc.out {
+				t.Fatalf("expected %d rows; got %d", tc.out, got)
+			}
+		})
+	}
+}
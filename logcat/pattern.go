@@ -0,0 +1,76 @@
+package logcat
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// patternMatch is the include and exclude patterns of one field. Each pattern
+// is compiled on its own so a row match when any include pattern match and no
+// exclude pattern match.
+type patternMatch struct {
+	include []*regexp.Regexp
+	exclude []*regexp.Regexp
+}
+
+// compilePatterns compiles the patterns with format, like "^(?:%s)$". Empty
+// patterns are skipped and nil is returned when there is no pattern left.
+func compilePatterns(name, format string, include, exclude []string) (*patternMatch, error) {
+	var m patternMatch
+	var err error
+	if m.include, err = compileEach(name, format, include); err != nil {
+		return nil, err
+	}
+	if m.exclude, err = compileEach("exclude-"+name, format, exclude); err != nil {
+		return nil, err
+	}
+	if len(m.include) == 0 && len(m.exclude) == 0 {
+		return nil, nil
+	}
+	return &m, nil
+}
+
+func compileEach(name, format string, patterns []string) ([]*regexp.Regexp, error) {
+	var regExps []*regexp.Regexp
+	for _, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+		regExp, err := regexp.Compile(fmt.Sprintf(format, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrBadFilter, name, err)
+		}
+		regExps = append(regExps, regExp)
+	}
+	return regExps, nil
+}
+
+// match return true if s match m, a nil m match everything
+func (m *patternMatch) match(s string) bool {
+	return m.matchValue(s, true)
+}
+
+// matchValue is match for values that may be missing, a missing value match
+// no pattern so it only match when there is no include pattern
+func (m *patternMatch) matchValue(s string, ok bool) bool {
+	if m == nil {
+		return true
+	}
+	if !ok {
+		return len(m.include) == 0
+	}
+	for _, r := range m.exclude {
+		if r.MatchString(s) {
+			return false
+		}
+	}
+	if len(m.include) == 0 {
+		return true
+	}
+	for _, r := range m.include {
+		if r.MatchString(s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,53 @@
+package logcat
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCatExclude(t *testing.T) {
+	healthRow := strings.Replace(testALBRow, "/status?a=b", "/health", 1)
+	postRow := strings.Replace(strings.Replace(testALBRow, `"GET `, `"POST `, 1), " 200 201 ", " 503 - ", 1)
+	rows := map[string]string{
+		"alb":      testALBRow,
+		"health":   healthRow,
+		"post":     postRow,
+		"rejected": testALBRejectedRow,
+	}
+	tt := []struct {
+		name   string
+		filter Filter
+		out    []string
+	}{
+		{"no-filter", Filter{}, []string{"alb", "health", "post", "rejected"}},
+		{"exclude-path", Filter{ExcludePath: []string{"/health"}}, []string{"alb", "post", "rejected"}},
+		{"exclude-path-prefix", Filter{ExcludePathPrefix: []string{"/heal", "/sta"}}, []string{"rejected"}},
+		{"exclude-elb-status-code", Filter{ExcludeElbStatusCode: []string{"2.."}}, []string{"post", "rejected"}},
+		{"exclude-target-status-code-dash", Filter{ExcludeTargetStatusCode: []string{"-"}}, []string{"alb", "health"}},
+		{"exclude-client-ip", Filter{ExcludeClientIP: []string{`10\.222\..*`}}, []string{"rejected"}},
+		{"exclude-http-method", Filter{ExcludeHTTPmethod: []string{"GET", "-"}}, []string{"post"}},
+		{"exclude-host", Filter{ExcludeHost: []string{"ELB01.prod.com"}}, []string{"rejected"}},
+		{"exclude-protocol", Filter{ExcludeProtocol: []string{"HTTP/1.1"}}, []string{"rejected"}},
+		{"exclude-query-param", Filter{ExcludeQueryParams: []string{"a=b"}}, []string{"health", "rejected"}},
+		{"any-elb-status-code", Filter{ElbStatusCode: []string{"400", "503"}}, []string{"post", "rejected"}},
+		{"any-http-method", Filter{HTTPmethod: []string{"POST", "-"}}, []string{"post", "rejected"}},
+		{"any-path", Filter{Path: []string{"/health", "/status"}}, []string{"alb", "health", "post"}},
+		{"include-and-exclude", Filter{ElbStatusCode: []string{"[2-5].."}, ExcludeElbStatusCode: []string{"4.."}}, []string{"alb", "health", "post"}},
+		{"exclude-wins", Filter{Path: []string{"/health"}, ExcludePathPrefix: []string{"/"}}, nil},
+		{"empty-patterns", Filter{ClientIP: []string{""}, ExcludeClientIP: []string{""}}, []string{"alb", "health", "post", "rejected"}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := matchingRows(t, rows, tc.filter); !reflect.DeepEqual(got, tc.out) {
+				t.Fatalf("should match %v; got %v", tc.out, got)
+			}
+		})
+	}
+
+	err := (Filter{ElbStatusCode: []string{"5.."}, ExcludeElbStatusCode: []string{"(5"}}).Validate()
+	if !errors.Is(err, ErrBadFilter) || !strings.Contains(err.Error(), "exclude-elb-status-code") {
+		t.Fatalf("bad exclude pattern should fail with ErrBadFilter and name the flag; got %v", err)
+	}
+}
@@ -0,0 +1,144 @@
+package logcat
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+type (
+	// queryParam is a parameter of the query of the request, with value when
+	// hasValue is set
+	queryParam struct {
+		name     string
+		value    string
+		hasValue bool
+	}
+
+	// requestMatch matches the parsed request url of the rows
+	requestMatch struct {
+		host          *patternMatch
+		pathPrefix    *patternMatch
+		path          *patternMatch
+		protocol      *patternMatch
+		queryParams   []queryParam
+		excludeParams []queryParam
+	}
+)
+
+func newRequestMatch(filter Filter) (*requestMatch, error) {
+	var r requestMatch
+	var err error
+	if r.host, err = compilePatterns("request-host", "(?i)^(?:%s)$", filter.Host, filter.ExcludeHost); err != nil {
+		return nil, err
+	}
+	if r.pathPrefix, err = compilePatterns("request-path-prefix", "^%s", quoteEach(filter.PathPrefix), quoteEach(filter.ExcludePathPrefix)); err != nil {
+		return nil, err
+	}
+	if r.path, err = compilePatterns("request-path", "^(?:%s)$", filter.Path, filter.ExcludePath); err != nil {
+		return nil, err
+	}
+	if r.protocol, err = compilePatterns("request-protocol", "(?i)^(?:%s)$", filter.Protocol, filter.ExcludeProtocol); err != nil {
+		return nil, err
+	}
+	if r.queryParams, err = parseQueryParams(filter.QueryParams); err != nil {
+		return nil, err
+	}
+	if r.excludeParams, err = parseQueryParams(filter.ExcludeQueryParams); err != nil {
+		return nil, err
+	}
+	if r.host == nil && r.pathPrefix == nil && r.path == nil && r.protocol == nil && r.queryParams == nil && r.excludeParams == nil {
+		return nil, nil
+	}
+	return &r, nil
+}
+
+// quoteEach quotes the regular expression characters of the prefixes
+func quoteEach(prefixes []string) []string {
+	var quoted []string
+	for _, prefix := range prefixes {
+		quoted = append(quoted, regexp.QuoteMeta(prefix))
+	}
+	return quoted
+}
+
+func parseQueryParams(params []string) ([]queryParam, error) {
+	var queryParams []queryParam
+	for _, param := range params {
+		if param == "" {
+			continue
+		}
+		q, err := parseQueryParam(param)
+		if err != nil {
+			return nil, err
+		}
+		queryParams = append(queryParams, q)
+	}
+	return queryParams, nil
+}
+
+// parseQueryParam parses name or name=value, both can be percent-encoded
+func parseQueryParam(param string) (queryParam, error) {
+	name, value := param, ""
+	i := strings.Index(param, "=")
+	if i >= 0 {
+		name, value = param[:i], param[i+1:]
+	}
+	var err error
+	if name, err = url.QueryUnescape(name); err != nil || name == "" {
+		return queryParam{}, fmt.Errorf("%w: query-param: bad parameter %q", ErrBadFilter, param)
+	}
+	if value, err = url.QueryUnescape(value); err != nil {
+		return queryParam{}, fmt.Errorf("%w: query-param: bad parameter %q", ErrBadFilter, param)
+	}
+	return queryParam{name: name, value: value, hasValue: i >= 0}, nil
+}
+
+// match return true if the request of e match all parts of r. Rows without a
+// request url, like the "- - - " of rejected connections, have no host, path
+// or query so they only match the parts that are excludes.
+func (r *requestMatch) match(e *Entry) bool {
+	if !r.protocol.match(e.RequestProtocol) {
+		return false
+	}
+	u, ok := e.requestURL()
+	var host, path string
+	var query url.Values
+	if ok {
+		host, path = u.Hostname(), u.Path
+		query, _ = url.ParseQuery(u.RawQuery)
+	}
+	if !r.host.matchValue(host, ok) || !r.pathPrefix.matchValue(path, ok) || !r.path.matchValue(path, ok) {
+		return false
+	}
+	for _, q := range r.excludeParams {
+		if q.in(query) {
+			return false
+		}
+	}
+	if len(r.queryParams) == 0 {
+		return true
+	}
+	for _, q := range r.queryParams {
+		if q.in(query) {
+			return true
+		}
+	}
+	return false
+}
+
+// in return true if the parameter is in query
+func (q queryParam) in(query url.Values) bool {
+	values, ok := query[q.name]
+	return ok && (!q.hasValue || contains(values, q.value))
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package logcat
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCatRequest(t *testing.T) {
+	encodedRow := strings.Replace(testALBRow, "https://elb01.prod.com:443/status?a=b", "https://API.prod.com:443/caf%C3%A9/menu%20items?q=hello+world&tag=a&tag=b&empty=", 1)
+	badURLRow := strings.Replace(testALBRow, "https://elb01.prod.com:443/status?a=b", "https://elb01.prod.com:443/%zz", 1)
+	noProtocolRow := strings.Replace(testALBRejectedRow, `"- - - "`, `"GET / "`, 1)
+	rows := map[string]string{
+		"alb":         testALBRow,
+		"http2":       testALBLatestRow,
+		"encoded":     encodedRow,
+		"rejected":    testALBRejectedRow,
+		"bad-url":     badURLRow,
+		"no-protocol": noProtocolRow,
+	}
+	tt := []struct {
+		name   string
+		filter Filter
+		out    []string
+	}{
+		{"host", Filter{Host: []string{"elb01.prod.com"}}, []string{"alb"}},
+		{"host-case-insensitive", Filter{Host: []string{`api\.prod\.com`}}, []string{"encoded"}},
+		{"host-regexp", Filter{Host: []string{`.*\.example\.com`}}, []string{"http2"}},
+		{"path-prefix", Filter{PathPrefix: []string{"/stat"}}, []string{"alb"}},
+		{"path-prefix-decoded", Filter{PathPrefix: []string{"/café/menu items"}}, []string{"encoded"}},
+		{"path-prefix-root", Filter{PathPrefix: []string{"/"}}, []string{"alb", "encoded", "http2", "no-protocol"}},
+		{"path", Filter{Path: []string{"/caf./[a-z ]+"}}, []string{"encoded"}},
+		{"path-whole", Filter{Path: []string{"/stat"}}, nil},
+		{"query-param-present", Filter{QueryParams: []string{"a"}}, []string{"alb"}},
+		{"query-param-empty-value", Filter{QueryParams: []string{"empty"}}, []string{"encoded"}},
+		{"query-param-value", Filter{QueryParams: []string{"q=hello world"}}, []string{"encoded"}},
+		{"query-param-encoded-value", Filter{QueryParams: []string{"q=hello%20world"}}, []string{"encoded"}},
+		{"query-param-repeated", Filter{QueryParams: []string{"tag=b"}}, []string{"encoded"}},
+		{"query-params-any", Filter{QueryParams: []string{"tag=c", "q=hello world"}}, []string{"encoded"}},
+		{"query-param-other-value", Filter{QueryParams: []string{"a=c"}}, nil},
+		{"protocol", Filter{Protocol: []string{"HTTP/2.0"}}, []string{"http2"}},
+		{"protocol-list", Filter{Protocol: []string{"http/1.1|HTTP/2.0"}}, []string{"alb", "bad-url", "encoded", "http2"}},
+		{"protocol-rejected", Filter{Protocol: []string{"-"}}, []string{"rejected"}},
+		{"host-and-path", Filter{Host: []string{"elb01.prod.com"}, PathPrefix: []string{"/status"}}, []string{"alb"}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := matchingRows(t, rows, tc.filter); !reflect.DeepEqual(got, tc.out) {
+				t.Fatalf("should match %v; got %v", tc.out, got)
+			}
+		})
+	}
+
+	for _, filter := range []Filter{{Host: []string{"("}}, {Path: []string{"[a-"}}, {Protocol: []string{"("}}, {QueryParams: []string{"=a"}}, {QueryParams: []string{"a=%zz"}}} {
+		if err := filter.Validate(); !errors.Is(err, ErrBadFilter) {
+			t.Errorf("%+v should fail with ErrBadFilter; got %v", filter, err)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package logcat
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Threshold is an inclusive range of a number field, a nil Min or Max is no limit
+type Threshold struct {
+	Min *float64
+	Max *float64
+}
+
+// byteUnits is the size suffixes accepted by ParseBytes, longest first
+var byteUnits = []struct {
+	suffix string
+	size   float64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30},
+	{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9},
+	{"K", 1e3}, {"M", 1e6}, {"G", 1e9},
+	{"B", 1},
+}
+
+// totalTimeFields is the processing times that make up the total time of a row
+var totalTimeFields = []string{"request_processing_time", "target_processing_time", "response_processing_time"}
+
+// IsSet return true if the threshold has a limit
+func (t Threshold) IsSet() bool {
+	return t.Min != nil || t.Max != nil
+}
+
+func (t Threshold) contains(v float64) bool {
+	return (t.Min == nil || v >= *t.Min) && (t.Max == nil || v <= *t.Max)
+}
+
+// ParseSeconds parses a time in seconds like 1.5, or a duration like 250ms or 2s
+func ParseSeconds(s string) (float64, error) {
+	if v, err := strconv.ParseFloat(s, 64); err == nil {
+		return v, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("bad time %q, should be seconds like 1.5 or a duration like 250ms", s)
+	}
+	return d.Seconds(), nil
+}
+
+// ParseBytes parses a size in bytes like 1048576, 10MB or 1MiB
+func ParseBytes(s string) (float64, error) {
+	number, size := s, 1.0
+	for _, unit := range byteUnits {
+		if strings.HasSuffix(s, unit.suffix) {
+			number, size = strings.TrimSuffix(s, unit.suffix), unit.size
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(strings.TrimSpace(number), 64)
+	if err != nil {
+		return 0, fmt.Errorf("bad size %q, should be bytes like 1048576, 10MB or 1MiB", s)
+	}
+	return v * size, nil
+}
+
+// matchThreshold return true if the number in the field name of e is within t.
+// The -1 written when the target never responded, and fields the row does not
+// have, are not within any threshold.
+func matchThreshold(t Threshold, e *Entry, name string) bool {
+	if !t.IsSet() {
+		return true
+	}
+	v, ok := numberField(e, name)
+	return ok && t.contains(v)
+}
+
+// matchTotalTime is matchThreshold for the sum of the processing times
+func matchTotalTime(t Threshold, e *Entry) bool {
+	if !t.IsSet() {
+		return true
+	}
+	total := 0.0
+	for _, name := range totalTimeFields {
+		v, ok := numberField(e, name)
+		if !ok {
+			return false
+		}
+		total += v
+	}
+	return t.contains(total)
+}
+
+func numberField(e *Entry, name string) (float64, bool) {
+	s, ok := e.Field(name)
+	if !ok {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	return v, err == nil && v >= 0
+}
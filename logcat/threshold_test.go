@@ -0,0 +1,83 @@
+package logcat
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseThresholdValues(t *testing.T) {
+	tt := []struct {
+		name  string
+		in    string
+		parse func(string) (float64, error)
+		out   float64
+	}{
+		{"seconds", "1.5", ParseSeconds, 1.5},
+		{"duration", "250ms", ParseSeconds, 0.25},
+		{"duration-seconds", "2s", ParseSeconds, 2},
+		{"bytes", "1048576", ParseBytes, 1048576},
+		{"megabytes", "10MB", ParseBytes, 10e6},
+		{"mebibytes", "1MiB", ParseBytes, 1 << 20},
+		{"kilo", "1.5K", ParseBytes, 1500},
+		{"byte-suffix", "512B", ParseBytes, 512},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tc.parse(tc.in)
+			if err != nil {
+				t.Fatalf("parse %q failed: %v", tc.in, err)
+			}
+			if got != tc.out {
+				t.Fatalf("parse %q should be %v; got %v", tc.in, tc.out, got)
+			}
+		})
+	}
+
+	if _, err := ParseSeconds("2 minutes"); err == nil {
+		t.Errorf("ParseSeconds of a bad time should fail")
+	}
+	if _, err := ParseBytes("10TB"); err == nil {
+		t.Errorf("ParseBytes of a bad size should fail")
+	}
+}
+
+func TestCatThresholds(t *testing.T) {
+	value := func(v float64) *float64 { return &v }
+	// the alb row has the processing times 0.000 0.002 0.000 and the sizes 371 178,
+	// the rejected row has -1 -1 -1 and 0 272
+	rows := testALBRow + "\n" + testALBRejectedRow + "\n"
+	tt := []struct {
+		name   string
+		filter Filter
+		out    int
+	}{
+		{"min-target-time", Filter{TargetTime: Threshold{Min: value(0.002)}}, 1},
+		{"min-target-time-above", Filter{TargetTime: Threshold{Min: value(0.003)}}, 0},
+		{"max-target-time-skips-sentinel", Filter{TargetTime: Threshold{Max: value(10)}}, 1},
+		{"min-request-time-zero-skips-sentinel", Filter{RequestTime: Threshold{Min: value(0)}}, 1},
+		{"max-response-time", Filter{ResponseTime: Threshold{Max: value(0)}}, 1},
+		{"max-total-time", Filter{TotalTime: Threshold{Max: value(0.002)}}, 1},
+		{"max-total-time-below", Filter{TotalTime: Threshold{Max: value(0.001)}}, 0},
+		{"min-sent-bytes", Filter{SentBytes: Threshold{Min: value(200)}}, 1},
+		{"sent-bytes-range", Filter{SentBytes: Threshold{Min: value(100), Max: value(300)}}, 2},
+		{"max-received-bytes", Filter{ReceivedBytes: Threshold{Max: value(0)}}, 1},
+		{"no-threshold", Filter{}, 2},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			a := Accesslog{Content: strings.NewReader(rows), RowFilter: tc.filter}
+			entries, err := a.Entries()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(entries) != tc.out {
+				t.Fatalf("should match %d rows; got %d", tc.out, len(entries))
+			}
+		})
+	}
+
+	if err := (Filter{SentBytes: Threshold{Min: value(10), Max: value(1)}}).Validate(); !errors.Is(err, ErrBadFilter) {
+		t.Fatalf("min above max should fail with ErrBadFilter; got %v", err)
+	}
+}
@@ -0,0 +1,356 @@
+package logcat
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A where expression filters rows on the values of their fields, like
+//
+//	elb_status_code >= 500 and target_processing_time > 1.5 and not request.path startswith "/health"
+//
+// Comparisons are written as <field> <operator> <value>. A number value compares
+// the field as a number, fields that are not a number like "-" never match.
+// A string value, in single or double quotes, compares the field as a string.
+// The operators are ==, !=, <, <=, >, >=, contains, startswith, endswith and
+// matches (a regular expression). A field without operator match when the field
+// has a value other than "-". Comparisons are combined with and, or, not and
+// parentheses, && || and ! can be used as well.
+
+type (
+	// WhereError is a syntax error in a where expression
+	WhereError struct {
+		Expr string
+		// Column is the 1 based position of the error in Expr
+		Column int
+		Msg    string
+	}
+
+	whereNode interface {
+		match(e *Entry) bool
+	}
+	whereAnd struct{ left, right whereNode }
+	whereOr  struct{ left, right whereNode }
+	whereNot struct{ node whereNode }
+	whereHas struct{ field string }
+	whereCmp struct {
+		field  string
+		op     string
+		str    string
+		num    float64
+		number bool
+		re     *regexp.Regexp
+	}
+
+	whereTokenKind int
+	whereToken     struct {
+		kind whereTokenKind
+		text string
+		pos  int
+	}
+
+	whereParser struct {
+		expr   string
+		tokens []whereToken
+		i      int
+	}
+)
+
+const (
+	tokEOF whereTokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+)
+
+var whereOperators = map[string]bool{
+	"==": true, "!=": true, "<": true, "<=": true, ">": true, ">=": true,
+	"contains": true, "startswith": true, "endswith": true, "matches": true,
+}
+
+func (e *WhereError) Error() string {
+	return fmt.Sprintf("where: column %d: %s", e.Column, e.Msg)
+}
+
+// Is makes errors.Is(err, ErrBadFilter) true for a WhereError
+func (e *WhereError) Is(target error) bool {
+	return target == ErrBadFilter
+}
+
+// compileWhere parses the where expression, an empty expression match everything
+func compileWhere(expr string) (whereNode, error) {
+	if strings.TrimSpace(expr) == "" {
+		return nil, nil
+	}
+	tokens, err := lexWhere(expr)
+	if err != nil {
+		return nil, err
+	}
+	p := &whereParser{expr: expr, tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.errorf(tok, "unexpected %s", tok.describe())
+	}
+	return node, nil
+}
+
+func lexWhere(expr string) ([]whereToken, error) {
+	var tokens []whereToken
+	i := 0
+	for i < len(expr) {
+		c := expr[i]
+		start := i
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+			continue
+		case c == '(':
+			tokens = append(tokens, whereToken{tokLParen, "(", start})
+			i++
+		case c == ')':
+			tokens = append(tokens, whereToken{tokRParen, ")", start})
+			i++
+		case c == '"' || c == '\'':
+			var sb strings.Builder
+			for i++; i < len(expr) && expr[i] != c; i++ {
+				if expr[i] == '\\' && i+1 < len(expr) {
+					i++
+				}
+				sb.WriteByte(expr[i])
+			}
+			if i >= len(expr) {
+				return nil, &WhereError{Expr: expr, Column: start + 1, Msg: "unterminated string"}
+			}
+			i++
+			tokens = append(tokens, whereToken{tokString, sb.String(), start})
+		case strings.ContainsRune("=!<>&|", rune(c)):
+			for i < len(expr) && strings.ContainsRune("=!<>&|", rune(expr[i])) {
+				i++
+			}
+			op := expr[start:i]
+			switch op {
+			case "=":
+				op = "=="
+			case "&&":
+				op = "and"
+			case "||":
+				op = "or"
+			case "!":
+				op = "not"
+			}
+			if op != "and" && op != "or" && op != "not" && !whereOperators[op] {
+				return nil, &WhereError{Expr: expr, Column: start + 1, Msg: fmt.Sprintf("unknown operator %q", expr[start:i])}
+			}
+			kind := tokOp
+			if op == "and" || op == "or" || op == "not" {
+				kind = tokIdent
+			}
+			tokens = append(tokens, whereToken{kind, op, start})
+		case c == '-' || c == '.' || (c >= '0' && c <= '9'):
+			for i++; i < len(expr) && isWhereIdent(expr[i]); i++ {
+			}
+			text := expr[start:i]
+			if _, err := strconv.ParseFloat(text, 64); err != nil {
+				return nil, &WhereError{Expr: expr, Column: start + 1, Msg: fmt.Sprintf("bad number %q", text)}
+			}
+			tokens = append(tokens, whereToken{tokNumber, text, start})
+		case isWhereIdent(c):
+			for i < len(expr) && isWhereIdent(expr[i]) {
+				i++
+			}
+			text := expr[start:i]
+			kind := tokIdent
+			if whereOperators[strings.ToLower(text)] {
+				kind, text = tokOp, strings.ToLower(text)
+			}
+			tokens = append(tokens, whereToken{kind, text, start})
+		default:
+			return nil, &WhereError{Expr: expr, Column: start + 1, Msg: fmt.Sprintf("unexpected character %q", c)}
+		}
+	}
+	return append(tokens, whereToken{tokEOF, "", len(expr)}), nil
+}
+
+// isWhereIdent return true for the characters of field names like client:port and request.path
+func isWhereIdent(c byte) bool {
+	return c < unicode.MaxASCII && (unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)) || strings.IndexByte("_.:-+", c) >= 0)
+}
+
+func (t whereToken) describe() string {
+	if t.kind == tokEOF {
+		return "end of expression"
+	}
+	return fmt.Sprintf("%q", t.text)
+}
+
+func (t whereToken) keyword(word string) bool {
+	return t.kind == tokIdent && strings.EqualFold(t.text, word)
+}
+
+func (p *whereParser) peek() whereToken {
+	return p.tokens[p.i]
+}
+
+func (p *whereParser) next() whereToken {
+	tok := p.tokens[p.i]
+	if tok.kind != tokEOF {
+		p.i++
+	}
+	return tok
+}
+
+func (p *whereParser) errorf(tok whereToken, format string, args ...interface{}) error {
+	return &WhereError{Expr: p.expr, Column: tok.pos + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+func (p *whereParser) parseOr() (whereNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().keyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = whereOr{left, right}
+	}
+	return left, nil
+}
+
+func (p *whereParser) parseAnd() (whereNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().keyword("and") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = whereAnd{left, right}
+	}
+	return left, nil
+}
+
+func (p *whereParser) parseNot() (whereNode, error) {
+	if p.peek().keyword("not") {
+		p.next()
+		node, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return whereNot{node}, nil
+	}
+	return p.parseTerm()
+}
+
+func (p *whereParser) parseTerm() (whereNode, error) {
+	tok := p.next()
+	switch {
+	case tok.kind == tokLParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, p.errorf(closing, "expected \")\" to close \"(\" at column %d, got %s", tok.pos+1, closing.describe())
+		}
+		return node, nil
+	case tok.kind != tokIdent || tok.keyword("and") || tok.keyword("or"):
+		return nil, p.errorf(tok, "expected field name, got %s", tok.describe())
+	case !isField(tok.text):
+		return nil, p.errorf(tok, "unknown field %q", tok.text)
+	}
+
+	if p.peek().kind != tokOp {
+		return whereHas{tok.text}, nil
+	}
+	op := p.next()
+	value := p.next()
+	if value.kind != tokNumber && value.kind != tokString {
+		return nil, p.errorf(value, "expected number or string after %s, got %s", op.describe(), value.describe())
+	}
+	cmp := whereCmp{field: tok.text, op: op.text, str: value.text}
+	switch op.text {
+	case "contains", "startswith", "endswith":
+		// the value is always compared as a string
+	case "matches":
+		re, err := regexp.Compile(value.text)
+		if err != nil {
+			return nil, p.errorf(value, "bad regular expression: %v", err)
+		}
+		cmp.re = re
+	default:
+		if value.kind == tokNumber {
+			cmp.num, _ = strconv.ParseFloat(value.text, 64)
+			cmp.number = true
+		}
+	}
+	return cmp, nil
+}
+
+func (n whereAnd) match(e *Entry) bool { return n.left.match(e) && n.right.match(e) }
+func (n whereOr) match(e *Entry) bool  { return n.left.match(e) || n.right.match(e) }
+func (n whereNot) match(e *Entry) bool { return !n.node.match(e) }
+
+func (n whereHas) match(e *Entry) bool {
+	val, ok := e.Field(n.field)
+	return ok && val != "" && val != "-"
+}
+
+func (n whereCmp) match(e *Entry) bool {
+	val, ok := e.Field(n.field)
+	if !ok {
+		return false
+	}
+	switch n.op {
+	case "contains":
+		return strings.Contains(val, n.str)
+	case "startswith":
+		return strings.HasPrefix(val, n.str)
+	case "endswith":
+		return strings.HasSuffix(val, n.str)
+	case "matches":
+		return n.re.MatchString(val)
+	}
+	var c int
+	if n.number {
+		f, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return false
+		}
+		switch {
+		case f < n.num:
+			c = -1
+		case f > n.num:
+			c = 1
+		}
+	} else {
+		c = strings.Compare(val, n.str)
+	}
+	switch n.op {
+	case "==":
+		return c == 0
+	case "!=":
+		return c != 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	}
+	return c >= 0
+}
@@ -0,0 +1,97 @@
+package logcat
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestWhere(t *testing.T) {
+	alb, err := Parse([]byte(testALBRow))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	rejected, err := Parse([]byte(testALBRejectedRow))
+	if err != nil {
+		t.Fatalf("parse failed: %v", err)
+	}
+	tt := []struct {
+		name     string
+		where    string
+		alb      bool
+		rejected bool
+	}{
+		{"number-eq", "elb_status_code == 200", true, false},
+		{"number-ge", "elb_status_code >= 400", false, true},
+		{"float", "target_processing_time > 0.001 and target_processing_time <= 0.002", true, false},
+		{"sentinel-is-a-number", "target_processing_time < 0", false, true},
+		{"dash-is-not-a-number", "target_status_code != 201", false, false},
+		{"string-eq", `type = "https"`, true, false},
+		{"single-quote", `client:port == '10.222.161.42:32774'`, true, false},
+		{"contains", `user_agent contains "Faraday"`, true, false},
+		{"startswith", `request.path startswith "/stat"`, true, false},
+		{"endswith", `domain_name endswith ".prod.com"`, true, false},
+		{"matches", `request.query matches "^a=.$"`, true, false},
+		{"not", `not request.path startswith "/health"`, true, true},
+		{"rejected-path", `request.path == "-"`, false, true},
+		{"bare-field", "target:port", true, false},
+		{"not-bare-field", "!target:port", false, true},
+		{"or", "elb_status_code >= 500 or request.method == 'GET'", true, false},
+		{"and-before-or", "type == 'http' or type == 'https' and elb_status_code == 400", false, true},
+		{"parentheses", "(type == 'http' or type == 'https') and elb_status_code == 400", false, true},
+		{"symbols", "(elb_status_code < 300 && client:port) || type == 'http'", true, true},
+		{"upper-case-keywords", "elb_status_code > 100 AND NOT type CONTAINS 'x'", true, true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			w, err := compileWhere(tc.where)
+			if err != nil {
+				t.Fatalf("compile %q failed: %v", tc.where, err)
+			}
+			if got := w.match(&alb); got != tc.alb {
+				t.Errorf("alb row: %q should be %v", tc.where, tc.alb)
+			}
+			if got := w.match(&rejected); got != tc.rejected {
+				t.Errorf("rejected row: %q should be %v", tc.where, tc.rejected)
+			}
+		})
+	}
+}
+
+func TestWhereError(t *testing.T) {
+	tt := []struct {
+		name   string
+		where  string
+		column int
+	}{
+		{"unknown-field", "elb_status_code > 500 and latency > 1", 27},
+		{"unknown-operator", "elb_status_code => 500", 17},
+		{"missing-value", "elb_status_code >", 18},
+		{"field-as-value", "elb_status_code > target_status_code", 19},
+		{"unterminated-string", `request.path == "/health`, 17},
+		{"bad-regexp", `request.path matches "(["`, 22},
+		{"bad-number", "target_processing_time > 1.5s", 26},
+		{"unclosed-parenthesis", "(type == 'http'", 16},
+		{"trailing", "type == 'http' 'https'", 16},
+		{"dangling-and", "type == 'http' and", 19},
+		{"bad-character", "type == 'http' ; ", 16},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := compileWhere(tc.where)
+			var whereErr *WhereError
+			if !errors.As(err, &whereErr) {
+				t.Fatalf("compile %q should fail with a WhereError; got %v", tc.where, err)
+			}
+			if whereErr.Column != tc.column {
+				t.Errorf("compile %q: column should be %d; got %d: %v", tc.where, tc.column, whereErr.Column, err)
+			}
+			if !errors.Is(err, ErrBadFilter) {
+				t.Errorf("compile %q: error should be ErrBadFilter", tc.where)
+			}
+		})
+	}
+
+	if w, err := compileWhere("  "); w != nil || err != nil {
+		t.Fatalf("empty where should match everything; got %v, %v", w, err)
+	}
+}
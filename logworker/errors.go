@@ -0,0 +1,36 @@
+package logworker
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrBadAccessLogName is returned when an object name is not a valid accesslog name
+	ErrBadAccessLogName = errors.New("bad accesslog name")
+	// ErrBadFilter is returned when the AccessLogFilter can not be compiled
+	ErrBadFilter = errors.New("bad accesslog filter")
+	// ErrListFailed is returned when listing the accesslogs in the bucket fails
+	ErrListFailed = errors.New("list accesslogs failed")
+)
+
+// ListError wraps the error returned by the Source when listing accesslogs fails.
+// errors.Is(err, ErrListFailed) is true for a ListError.
+type ListError struct {
+	URL string
+	Err error
+}
+
+func (e *ListError) Error() string {
+	return fmt.Sprintf("%v: %s: %v", ErrListFailed, e.URL, e.Err)
+}
+
+// Unwrap return the error from the Source
+func (e *ListError) Unwrap() error {
+	return e.Err
+}
+
+// Is make errors.Is match ErrListFailed
+func (e *ListError) Is(target error) bool {
+	return target == ErrListFailed
+}
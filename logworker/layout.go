@@ -0,0 +1,101 @@
+package logworker
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultKeyTemplate is the layout the load balancer writes the logs in
+	DefaultKeyTemplate string = "{prefix}/AWSLogs/{account}/elasticloadbalancing/{region}/{date}/"
+	// OrgKeyTemplate is the layout of a log bucket shared by an AWS Organization
+	OrgKeyTemplate string = "{prefix}/AWSLogs/{org_id}/{account}/elasticloadbalancing/{region}/{date}/"
+	// KeyTemplateAuto makes the LogWorker probe the bucket for the layout it use
+	KeyTemplateAuto string = "auto"
+
+	orgIDPrefix string = "o-"
+)
+
+// keyTemplate return the template of the day prefixes, DefaultKeyTemplate when
+// no template is set or it is not detected yet
+func (a *AccessLogFilter) keyTemplate() string {
+	if a.KeyTemplate == "" || a.KeyTemplate == KeyTemplateAuto {
+		return DefaultKeyTemplate
+	}
+	return a.KeyTemplate
+}
+
+// validKeyTemplate return an error when the template miss any of the placeholders
+// that is needed to find the logs of one day
+func validKeyTemplate(template string) error {
+	if template == "" || template == KeyTemplateAuto {
+		return nil
+	}
+	for _, placeholder := range []string{"{account}", "{region}", "{date}"} {
+		if !strings.Contains(template, placeholder) {
+			return fmt.Errorf("%w: key template %q has no %s", ErrBadFilter, template, placeholder)
+		}
+	}
+	return nil
+}
+
+// expand replace the placeholders of template with the values of the target.
+// The result is a prefix that ends with a slash and has no empty path elements.
+func (t Target) expand(template, prefix string, day time.Time) string {
+	key := strings.NewReplacer(
+		"{prefix}", prefix,
+		"{org_id}", t.OrgID,
+		"{account}", t.AwsAccountID,
+		"{region}", t.Region,
+		"{date}", day.UTC().Format("2006/01/02"),
+	).Replace(template)
+	key = strings.TrimPrefix(path.Clean("/"+key), "/")
+	if key == "" {
+		return ""
+	}
+	return key + "/"
+}
+
+// listPlaceholder list the values of placeholder in the bucket. The template is
+// expanded with t up to the placeholder, nil is returned when the template
+// has no such placeholder.
+func (l *LogWorker) listPlaceholder(t Target, placeholder string) ([]string, error) {
+	template := l.AccessLogFilter.keyTemplate()
+	i := strings.Index(template, placeholder)
+	if i < 0 {
+		return nil, nil
+	}
+	return l.Source.ListDirs(t.expand(template[:i], l.Configuration.Prefix, time.Time{}))
+}
+
+// detectKeyTemplate set the key template to OrgKeyTemplate when the AWSLogs
+// directory only holds organization ids and to DefaultKeyTemplate otherwise.
+// A bucket with both layouts is listed with DefaultKeyTemplate.
+func (l *LogWorker) detectKeyTemplate() error {
+	if l.AccessLogFilter.KeyTemplate != KeyTemplateAuto {
+		return nil
+	}
+	dirs, err := l.Source.ListDirs(Target{}.expand("{prefix}/AWSLogs/", l.Configuration.Prefix, time.Time{}))
+	if err != nil {
+		return err
+	}
+	var orgs, accounts int
+	for _, dir := range dirs {
+		if strings.HasPrefix(dir, orgIDPrefix) {
+			orgs++
+		} else {
+			accounts++
+		}
+	}
+	l.AccessLogFilter.KeyTemplate = DefaultKeyTemplate
+	switch {
+	case orgs > 0 && accounts == 0:
+		l.AccessLogFilter.KeyTemplate = OrgKeyTemplate
+	case orgs > 0:
+		Logger.Warnf("the bucket has logs in both the account and the organization layout, the organization logs are skipped. Use --key-template %v to list them", OrgKeyTemplate)
+	}
+	Logger.Debugf("using key template %v", l.AccessLogFilter.KeyTemplate)
+	return nil
+}
@@ -0,0 +1,120 @@
+package logworker
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTargetExpand(t *testing.T) {
+	day := time.Date(2019, 2, 23, 14, 45, 0, 0, time.UTC)
+	target := Target{OrgID: "o-abcd123456", AwsAccountID: "0123456789", Region: "eu-west-1"}
+	tt := []struct {
+		name     string
+		template string
+		prefix   string
+		out      string
+	}{
+		{"default", DefaultKeyTemplate, "", "AWSLogs/0123456789/elasticloadbalancing/eu-west-1/2019/02/23/"},
+		{"default-prefix", DefaultKeyTemplate, "team-xxx/", "team-xxx/AWSLogs/0123456789/elasticloadbalancing/eu-west-1/2019/02/23/"},
+		{"org", OrgKeyTemplate, "archive", "archive/AWSLogs/o-abcd123456/0123456789/elasticloadbalancing/eu-west-1/2019/02/23/"},
+		{"custom", "elb/{region}/{account}/{date}", "", "elb/eu-west-1/0123456789/2019/02/23/"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := target.expand(tc.template, tc.prefix, day); got != tc.out {
+				t.Fatalf("expand(%v) should be %v; got %v", tc.template, tc.out, got)
+			}
+		})
+	}
+}
+
+func TestValidKeyTemplate(t *testing.T) {
+	for _, template := range []string{"", KeyTemplateAuto, DefaultKeyTemplate, OrgKeyTemplate} {
+		if err := validKeyTemplate(template); err != nil {
+			t.Fatalf("validKeyTemplate(%q) unexpected error: %v", template, err)
+		}
+	}
+	if err := validKeyTemplate("{prefix}/AWSLogs/{account}/{date}/"); !errors.Is(err, ErrBadFilter) {
+		t.Fatalf("template without {region} should return ErrBadFilter; got %v", err)
+	}
+}
+
+func TestLogWorkerOrgLayout(t *testing.T) {
+	root := t.TempDir()
+	key := "archive/AWSLogs/%s/0123456789/elasticloadbalancing/eu-west-1/2019/02/23/0123456789_elasticloadbalancing_eu-west-1_app.elb-prod.32435435_20190223T1455Z_10.205.19.102_34cjbbr9.log.gz"
+	writeTestLogs(t, root,
+		fmt.Sprintf(key, "o-abcd123456"),
+		fmt.Sprintf(key, "o-efgh123456"),
+	)
+	tt := []struct {
+		name        string
+		keyTemplate string
+		orgID       string
+		keys        []string
+	}{
+		{"auto", KeyTemplateAuto, "", []string{fmt.Sprintf(key, "o-abcd123456"), fmt.Sprintf(key, "o-efgh123456")}},
+		{"auto-org-id", KeyTemplateAuto, "o-efgh123456", []string{fmt.Sprintf(key, "o-efgh123456")}},
+		{"template", OrgKeyTemplate, "o-abcd123456", []string{fmt.Sprintf(key, "o-abcd123456")}},
+		{"default-layout", "", "", nil},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			accessLogFilter := AccessLogFilter{
+				KeyTemplate:    tc.keyTemplate,
+				OrgID:          tc.orgID,
+				AwsAccountID:   "0123456789",
+				Region:         "eu-west-1",
+				LoadBalancerID: ".*",
+				IPaddress:      ".*",
+				RandomString:   ".*",
+				StartTime:      time.Date(2019, 2, 23, 14, 45, 0, 0, time.UTC),
+				EndTime:        time.Date(2019, 2, 23, 15, 0, 0, 0, time.UTC),
+			}
+			client, err := NewLogWorker(&AWSconfiguration{}, &Configuration{Prefix: "archive"}, &accessLogFilter, WithSource(NewFileSource(root)))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			keys, err := client.ListKeys()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(keys, tc.keys) {
+				t.Fatalf("ListKeys should return %v; got %v", tc.keys, keys)
+			}
+		})
+	}
+}
+
+func TestLogWorkerMixedLayout(t *testing.T) {
+	root := t.TempDir()
+	name := "0123456789_elasticloadbalancing_eu-west-1_app.elb-prod.32435435_20190223T1455Z_10.205.19.102_34cjbbr9.log.gz"
+	plainKey := "AWSLogs/0123456789/elasticloadbalancing/eu-west-1/2019/02/23/" + name
+	writeTestLogs(t, root,
+		plainKey,
+		"AWSLogs/o-abcd123456/0123456789/elasticloadbalancing/eu-west-1/2019/02/23/"+name,
+	)
+	accessLogFilter := AccessLogFilter{
+		KeyTemplate:    KeyTemplateAuto,
+		AwsAccountID:   "0123456789",
+		Region:         "eu-west-1",
+		LoadBalancerID: ".*",
+		IPaddress:      ".*",
+		RandomString:   ".*",
+		StartTime:      time.Date(2019, 2, 23, 14, 45, 0, 0, time.UTC),
+		EndTime:        time.Date(2019, 2, 23, 15, 0, 0, 0, time.UTC),
+	}
+	client, err := NewLogWorker(&AWSconfiguration{}, &Configuration{}, &accessLogFilter, WithSource(NewFileSource(root)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	keys, err := client.ListKeys()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := []string{plainKey}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("a bucket with both layouts should be listed with the account layout %v; got %v", want, keys)
+	}
+}
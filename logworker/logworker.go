@@ -2,34 +2,40 @@ package logworker
 
 import (
 	"fmt"
+	"io"
 	"os"
-	"path/filepath"
+	"path"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/s3"
-	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/sirupsen/logrus"
-	"github.com/spf13/viper"
 )
 
 type (
 	// LogWorker worker
 	LogWorker struct {
 		Config          *AWSconfiguration
-		S3              *s3.S3
-		S3Downloader    *s3manager.Downloader
+		Source          Source
 		Configuration   *Configuration
 		AccessLogFilter *AccessLogFilter
+		session         *session.Session
 	}
+	// Option configures optional parts of the LogWorker
+	Option func(*LogWorker)
 	//AWSconfiguration --..
 	AWSconfiguration struct {
 		Region  string
 		Profile string
+		// RoleARN is assumed with the credentials of Profile when it is set
+		RoleARN    string
+		ExternalID string
+		// S3Endpoint, S3ForcePathStyle and S3DisableSSL is used for s3 compatible stores
+		S3Endpoint       string
+		S3ForcePathStyle bool
+		S3DisableSSL     bool
 	}
 	// Configuration hold the configuration that is needed.
 	Configuration struct {
@@ -38,9 +44,36 @@ type (
 		PollingInterval time.Duration
 		MaxKeys         int64
 	}
+	// AccessLogFilterOptions holds the unparsed values used by NewAccessLogFilter.
+	// StartTime and EndTime are time expressions, see ParseTime, in the location TZ
+	// (UTC when empty). They default to today and now. Since is a duration or a time
+	// expression used instead of StartTime and Last is a duration back from now.
+	// AwsAccountID, Region and OrgID is a comma separated list or all.
+	AccessLogFilterOptions struct {
+		OrgID          string
+		AwsAccountID   string
+		Region         string
+		LoadBalancerID string
+		IPaddress      string
+		RandomString   string
+		StartTime      string
+		EndTime        string
+		Since          string
+		Last           string
+		TZ             string
+		LogKind        string
+		KeyTemplate    string
+		// Now is the time relative times are from, the current time when it is zero
+		Now time.Time
+	}
+	// LogKind is the kind of log that is written by the load balancer
+	LogKind string
 	// AccessLogFilter ..
 	AccessLogFilter struct {
 		matchString    string
+		LogKind        LogKind
+		KeyTemplate    string
+		OrgID          string
 		AwsAccountID   string
 		Region         string
 		LoadBalancerID string
@@ -50,10 +83,38 @@ type (
 		EndTime        time.Time
 		matcher        *regexp.Regexp
 	}
+	// Target is one account and region that the logs are listed for
+	Target struct {
+		OrgID        string
+		AwsAccountID string
+		Region       string
+	}
+	// AccessLogName is the parts of the name of a log object
+	AccessLogName struct {
+		AwsAccountID   string
+		Region         string
+		LoadBalancerID string
+		EndTime        time.Time
+		IPaddress      string
+		RandomString   string
+	}
 )
 
 const (
-	accessLogEndTimeFormat string = "20060102T1504Z"
+	accessLogEndTimeFormat  string = "20060102T1504Z"
+	accessLogEndTimePattern string = "[0-9]{8}T[0-9]{4}Z"
+	maxConcurrentListings   int    = 8
+	connectionLogPrefix     string = "conn_log."
+	// nlbPrefix is the start of the network load balancer ids
+	nlbPrefix string = "net."
+
+	// All as AwsAccountID or Region lists every account or region in the bucket
+	All string = "all"
+
+	// LogKindAccess is the accesslog of alb, nlb and classic elb
+	LogKindAccess LogKind = "access"
+	// LogKindConnection is the alb connection log
+	LogKindConnection LogKind = "connection"
 )
 
 var (
@@ -72,22 +133,55 @@ func init() {
 
 }
 
-func newFilter(accessLogFilter *AccessLogFilter) *regexp.Regexp {
-	matchString := fmt.Sprintf("^(%s)_(elasticloadbalancing)_(%s)_(%s)_(%s)_(%s)_(%s).log.gz$",
-		accessLogFilter.AwsAccountID,
-		accessLogFilter.Region,
+func newFilter(accessLogFilter *AccessLogFilter) (*regexp.Regexp, error) {
+	// network load balancers write no node ip in the name, their logs are
+	// only listed when the ip filter match an empty ip. The nlb group is
+	// checked to be a net. load balancer by matchName.
+	var nlb string
+	if ip, err := regexp.Compile(`^(?:` + accessLogFilter.IPaddress + `)$`); err == nil && ip.MatchString("") {
+		nlb = fmt.Sprintf(`|(?P<nlb>%s)_(%s)_(%s)`,
+			accessLogFilter.LoadBalancerID,
+			accessLogEndTimePattern,
+			accessLogFilter.RandomString,
+		)
+	}
+	// Classic elb writes uncompressed .log objects.
+	matchString := fmt.Sprintf(`^%s(%s)_(elasticloadbalancing)_(%s)_(?:(%s)_(%s)_(%s)_(%s)%s)\.log(\.gz)?$`,
+		regexp.QuoteMeta(accessLogFilter.namePrefix()),
+		listPattern(accessLogFilter.AwsAccountID),
+		listPattern(accessLogFilter.Region),
 		accessLogFilter.LoadBalancerID,
-		".*",
+		accessLogEndTimePattern,
 		accessLogFilter.IPaddress,
 		accessLogFilter.RandomString,
+		nlb,
 	)
 
 	regexp, err := regexp.Compile(matchString)
 	if err != nil {
-		Logger.Fatalf("Failed to compile matchstring. Gott error: %v", err)
+		return nil, fmt.Errorf("%w: %v", ErrBadFilter, err)
 	}
 
-	return regexp
+	return regexp, nil
+}
+
+// listPattern return the regexp that match any of the values in the list
+func listPattern(list string) string {
+	if list == All {
+		return ".*"
+	}
+	return strings.Join(splitList(list), "|")
+}
+
+// splitList splits a comma separated list, empty values are dropped
+func splitList(list string) []string {
+	var values []string
+	for _, v := range strings.Split(list, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
 }
 
 // NewLogWorker return a pointer of LogWorker
@@ -95,105 +189,212 @@ func NewLogWorker(
 	awsConfiguration *AWSconfiguration,
 	configuration *Configuration,
 	accessLogFilter *AccessLogFilter,
-) *LogWorker {
+	opts ...Option,
+) (*LogWorker, error) {
+	matcher, err := newFilter(accessLogFilter)
+	if err != nil {
+		return nil, err
+	}
 	logWorker := LogWorker{}
+	for _, opt := range opts {
+		opt(&logWorker)
+	}
+	logWorker.Config = awsConfiguration
 	logWorker.Configuration = configuration
 	logWorker.AccessLogFilter = accessLogFilter
-	logWorker.AccessLogFilter.matcher = newFilter(accessLogFilter)
+	logWorker.AccessLogFilter.matcher = matcher
 
-	awsCfg := aws.Config{}
-	if awsConfiguration.Region != "" {
-		awsCfg.Region = &awsConfiguration.Region
+	if logWorker.Source != nil {
+		return &logWorker, nil
 	}
 
-	awsSessionOpts := session.Options{
-		Config:                  awsCfg,
-		AssumeRoleTokenProvider: stscreds.StdinTokenProvider,
-		SharedConfigState:       session.SharedConfigEnable,
+	sess := logWorker.session
+	if sess == nil {
+		sess, err = newSession(awsConfiguration)
+		if err != nil {
+			return nil, err
+		}
 	}
-
-	if awsConfiguration.Profile != "" {
-		awsSessionOpts.Profile = awsConfiguration.Profile
+	client, err := newS3Client(sess, awsConfiguration, configuration.Bucket)
+	if err != nil {
+		return nil, err
 	}
+	logWorker.Source = NewS3Source(client, configuration.Bucket, configuration.MaxKeys)
 
-	sess := session.Must(session.NewSessionWithOptions(awsSessionOpts))
-
-	logWorker.S3 = s3.New(sess)
-	logWorker.S3Downloader = s3manager.NewDownloader(sess)
-
-	return &logWorker
+	return &logWorker, nil
 }
 
 // List returns slice of string with accesslog names
-func (l *LogWorker) List() []string {
+func (l *LogWorker) List() ([]string, error) {
+	keys, err := l.ListKeys()
+	if err != nil {
+		return nil, err
+	}
+	var accessLogs []string
+	for _, key := range keys {
+		accessLogs = append(accessLogs, path.Base(key))
+	}
+	return accessLogs, nil
+}
+
+// ListKeys returns the keys of the accesslogs between StartTime and EndTime
+// for every target. The day prefixes are listed concurrently and the keys are
+// returned in day order, and in target order within the day.
+func (l *LogWorker) ListKeys() ([]string, error) {
+	targets, err := l.Targets()
+	if err != nil {
+		return nil, err
+	}
+	var prefixes []string
+	for _, day := range l.AccessLogFilter.days() {
+		for _, target := range targets {
+			prefixes = append(prefixes, target.expand(l.AccessLogFilter.keyTemplate(), l.Configuration.Prefix, day))
+		}
+	}
+	results := make([][]string, len(prefixes))
+	errs := make([]error, len(prefixes))
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxConcurrentListings)
+	for i, prefix := range prefixes {
+		wg.Add(1)
+		go func(i int, prefix string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i], errs[i] = l.listDay(prefix)
+		}(i, prefix)
+	}
+	wg.Wait()
 
 	var accessLogs []string
-	input := &s3.ListObjectsV2Input{
-		Bucket:    aws.String(l.Configuration.Bucket),
-		Prefix:    aws.String(l.AccessLogFilter.AccesslogPath(l.Configuration.Prefix)),
-		Delimiter: aws.String("/"),
-		MaxKeys:   aws.Int64(200),
-	}
-	err := l.S3.ListObjectsV2Pages(input,
-		func(page *s3.ListObjectsV2Output, lastPage bool) bool {
-			for _, val := range page.Contents {
-				accessLog := strings.Split(*val.Key, "/")[len(strings.Split(*val.Key, "/"))-1]
-				if l.AccessLogFilter.matcher.MatchString(accessLog) && l.AccessLogFilter.filterByTime(accessLog) {
-					accessLogs = append(accessLogs, accessLog)
-				}
+	for i := range prefixes {
+		if errs[i] != nil {
+			return nil, errs[i]
+		}
+		accessLogs = append(accessLogs, results[i]...)
+	}
+	return accessLogs, nil
+}
+
+// Targets return every combination of the organizations, accounts and regions
+// of the filter. The ones that are all is found by listing the bucket.
+func (l *LogWorker) Targets() ([]Target, error) {
+	if err := l.detectKeyTemplate(); err != nil {
+		return nil, err
+	}
+	orgs, err := l.targetValues(Target{}, "{org_id}", l.AccessLogFilter.OrgID)
+	if err != nil {
+		return nil, err
+	}
+	var targets []Target
+	for _, org := range orgs {
+		accounts, err := l.targetValues(Target{OrgID: org}, "{account}", l.AccessLogFilter.AwsAccountID)
+		if err != nil {
+			return nil, err
+		}
+		for _, account := range accounts {
+			regions, err := l.targetValues(Target{OrgID: org, AwsAccountID: account}, "{region}", l.AccessLogFilter.Region)
+			if err != nil {
+				return nil, err
 			}
-			return true
-		})
+			for _, region := range regions {
+				targets = append(targets, Target{OrgID: org, AwsAccountID: account, Region: region})
+			}
+		}
+	}
+	return targets, nil
+}
+
+// targetValues return the values in list, the values of placeholder in the bucket
+// when list is all. An empty organization list is the same as all.
+func (l *LogWorker) targetValues(t Target, placeholder, list string) ([]string, error) {
+	if list == All || (placeholder == "{org_id}" && list == "") {
+		values, err := l.listPlaceholder(t, placeholder)
+		if values == nil && err == nil {
+			// the key template has no such placeholder
+			values = []string{list}
+		}
+		return values, err
+	}
+	return splitList(list), nil
+}
+
+// listDay returns the keys under the day prefix that match the filter
+func (l *LogWorker) listDay(prefix string) ([]string, error) {
+	keys, err := l.Source.List(prefix)
 	if err != nil {
-		Logger.Fatalf("listObjectV2Pages return with error: %v", err)
+		return nil, err
+	}
+	var accessLogs []string
+	for _, key := range keys {
+		accessLog := path.Base(key)
+		if !l.AccessLogFilter.matchName(accessLog) {
+			continue
+		}
+		ok, err := l.AccessLogFilter.filterByTime(accessLog)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			accessLogs = append(accessLogs, key)
+		}
 	}
-	return accessLogs
+	return accessLogs, nil
+}
+
+// Open return a stream of the accesslog with key as returned by ListKeys and Tail
+func (l *LogWorker) Open(key string) (io.ReadCloser, error) {
+	return l.Source.Open(key)
 }
 
-// Tail return chan of string
-func (l *LogWorker) Tail(logch chan<- string) {
+// Tail sends the keys of new accesslogs of every target to logch. Errors
+// listing the bucket are sent to errch and the listing is retried at the next
+// polling interval.
+func (l *LogWorker) Tail(logch chan<- string, errch chan<- error) {
 	go func() {
-		accessLogFilter := NewAccessLogFilter()
+		var targets []Target
 		consumedAccessLogs := make(map[string]struct{})
-
-		lbAccessLogTimestamp := l.AccessLogFilter.StartTime
-		for t := lbAccessLogTimestamp; t.Before(time.Now().UTC()); t = t.Add(5 * time.Minute) {
-			lbAccessLogTimestamp = t
-			lbAccessLog := fmt.Sprintf("%s_elasticloadbalancing_%s_%s_%s",
-				accessLogFilter.AwsAccountID,
-				accessLogFilter.Region,
-				accessLogFilter.LoadBalancerID,
-				t.Format(accessLogEndTimeFormat),
-			)
-			s3Prefix := filepath.Join(l.AccessLogFilter.AccesslogPath(l.Configuration.Prefix), lbAccessLog)
-			for _, accessLog := range *l.listAccessLogs(s3Prefix) {
-				if _, ok := consumedAccessLogs[accessLog]; !ok {
-					consumedAccessLogs[accessLog] = struct{}{}
-					logch <- accessLog
+		poll := func(t time.Time) {
+			if targets == nil {
+				var err error
+				if targets, err = l.Targets(); err != nil {
+					errch <- err
+					return
+				}
+			}
+			for _, target := range targets {
+				lbAccessLog := fmt.Sprintf("%s%s_elasticloadbalancing_%s_%s_%s",
+					l.AccessLogFilter.namePrefix(),
+					target.AwsAccountID,
+					target.Region,
+					l.AccessLogFilter.LoadBalancerID,
+					t.Format(accessLogEndTimeFormat),
+				)
+				s3Prefix := target.expand(l.AccessLogFilter.keyTemplate(), l.Configuration.Prefix, t) + lbAccessLog
+				accessLogs, err := l.Source.List(s3Prefix)
+				if err != nil {
+					errch <- err
+					continue
+				}
+				for _, accessLog := range accessLogs {
+					if _, ok := consumedAccessLogs[accessLog]; !ok {
+						consumedAccessLogs[accessLog] = struct{}{}
+						logch <- accessLog
+					}
 				}
 			}
 		}
 
+		for t := l.AccessLogFilter.StartTime; t.Before(time.Now().UTC()); t = t.Add(5 * time.Minute) {
+			poll(t)
+		}
+
 		poller := time.Tick(l.Configuration.PollingInterval)
 		for now := range poller {
-
-			lbAccessLogTimestamp = lbAccessLogTimestamp.Add(15 * time.Second)
-			lbAccessLog := fmt.Sprintf("%s_elasticloadbalancing_%s_%s_%s",
-				accessLogFilter.AwsAccountID,
-				accessLogFilter.Region,
-				accessLogFilter.LoadBalancerID,
-				now.UTC().Format(accessLogEndTimeFormat),
-			)
-			s3Prefix := filepath.Join(l.AccessLogFilter.AccesslogPath(l.Configuration.Prefix), lbAccessLog)
-			for _, accessLog := range *l.listAccessLogs(s3Prefix) {
-				if _, ok := consumedAccessLogs[accessLog]; !ok {
-					consumedAccessLogs[accessLog] = struct{}{}
-					logch <- accessLog
-				}
-			}
+			poll(now.UTC())
 			for k := range consumedAccessLogs {
-				ts := strings.Split(k, "_")
-				t, _ := time.Parse(accessLogEndTimeFormat, ts[4])
+				t, _ := accessLogEndTime(path.Base(k))
 				if t.Before(now.UTC().Add(-2 * time.Minute)) {
 					delete(consumedAccessLogs, k)
 				}
@@ -203,91 +404,226 @@ func (l *LogWorker) Tail(logch chan<- string) {
 	}()
 }
 
-func (l *LogWorker) listAccessLogs(s3Prefix string) *[]string {
-	var al []string
-	input := &s3.ListObjectsV2Input{
-		Bucket:    aws.String(l.Configuration.Bucket),
-		Prefix:    aws.String(s3Prefix),
-		Delimiter: aws.String("/"),
-		MaxKeys:   aws.Int64(l.Configuration.MaxKeys),
-	}
-	err := l.S3.ListObjectsV2Pages(input,
-		func(page *s3.ListObjectsV2Output, lastPage bool) bool {
-			for _, val := range page.Contents {
-				accessLog := strings.Split(*val.Key, "/")[len(strings.Split(*val.Key, "/"))-1]
-				al = append(al, accessLog)
-			}
-			return true
-		})
-	if err != nil {
-		Logger.Fatalf("listObjectV2Pages return with error: %v", err)
+// AccesslogPath return string of the key of accesslog (accesslog with full path of s3)
+func (a *AccessLogFilter) AccesslogPath(prefix string) string {
+	return a.accesslogPathAt(prefix, a.StartTime)
+}
+
+// AccesslogPaths return the day prefixes of every UTC day between StartTime and
+// EndTime. The day after EndTime is included when the accesslog that holds EndTime
+// ends after midnight.
+func (a *AccessLogFilter) AccesslogPaths(prefix string) []string {
+	var paths []string
+	for _, day := range a.days() {
+		paths = append(paths, a.accesslogPathAt(prefix, day))
 	}
-	return &al
+	return paths
 }
 
-// AccesslogPath return string of the key of accesslog (accesslog with full path of s3)
-func (a *AccessLogFilter) AccesslogPath(prefix string) string {
-	return filepath.Join(prefix, fmt.Sprintf("AWSLogs/%s/elasticloadbalancing/%s/%s/", a.AwsAccountID, a.Region, a.StartTime.Format("2006/01/02"))) + "/"
+// days return every UTC day between StartTime and EndTime
+func (a *AccessLogFilter) days() []time.Time {
+	var days []time.Time
+	day := a.StartTime.UTC().Truncate(24 * time.Hour)
+	last := a.EndTime.UTC().Add(5 * time.Minute)
+	for ; !day.After(last); day = day.Add(24 * time.Hour) {
+		days = append(days, day)
+	}
+	return days
+}
+
+// MultipleTargets return true when the filter can match more than one account and region
+func (a *AccessLogFilter) MultipleTargets() bool {
+	return a.OrgID == All || len(splitList(a.OrgID)) > 1 ||
+		a.AwsAccountID == All || a.Region == All ||
+		len(splitList(a.AwsAccountID)) > 1 || len(splitList(a.Region)) > 1
+}
+
+func (a *AccessLogFilter) accesslogPathAt(prefix string, t time.Time) string {
+	return Target{OrgID: a.OrgID, AwsAccountID: a.AwsAccountID, Region: a.Region}.expand(a.keyTemplate(), prefix, t)
+}
+
+// namePrefix return the prefix of the log object names for the LogKind
+func (a *AccessLogFilter) namePrefix() string {
+	if a.LogKind == LogKindConnection {
+		return connectionLogPrefix
+	}
+	return ""
+}
 
+// matchName return true if the log name is of the LogKind and match the filter
+func (a *AccessLogFilter) matchName(accessLog string) bool {
+	if strings.HasPrefix(accessLog, connectionLogPrefix) != (a.LogKind == LogKindConnection) {
+		return false
+	}
+	m := a.matcher.FindStringSubmatch(accessLog)
+	if m == nil {
+		return false
+	}
+	if i := a.matcher.SubexpIndex("nlb"); i >= 0 && m[i] != "" {
+		return strings.HasPrefix(m[i], nlbPrefix)
+	}
+	return true
 }
 
-func (a *AccessLogFilter) filterByTime(accessLog string) bool {
-	accessLogEndTimeStr := strings.Split(accessLog, "_")[4]
-	accessLogEndTimeStamp, err := time.Parse(accessLogEndTimeFormat, accessLogEndTimeStr)
+// accessLogEndTime return the end time that is part of the accesslog name
+func accessLogEndTime(accessLog string) (time.Time, error) {
+	name, err := ParseAccessLogName(accessLog)
 	if err != nil {
-		Logger.Fatalf("failed to parse timestamp for accesslog name")
+		return time.Time{}, err
+	}
+	return name.EndTime, nil
+}
+
+// ParseAccessLogName parses the name (without directory) of an access or connection log
+func ParseAccessLogName(accessLog string) (AccessLogName, error) {
+	parts := strings.Split(strings.TrimPrefix(accessLog, connectionLogPrefix), "_")
+	if len(parts) < 5 {
+		return AccessLogName{}, fmt.Errorf("%w %q: no end time", ErrBadAccessLogName, accessLog)
+	}
+	t, err := time.Parse(accessLogEndTimeFormat, parts[4])
+	if err != nil {
+		return AccessLogName{}, fmt.Errorf("%w %q: %v", ErrBadAccessLogName, accessLog, err)
+	}
+	name := AccessLogName{
+		AwsAccountID:   parts[0],
+		Region:         parts[2],
+		LoadBalancerID: parts[3],
+		EndTime:        t,
+	}
+	switch {
+	case len(parts) > 6:
+		name.IPaddress = parts[5]
+		name.RandomString = strings.TrimSuffix(strings.TrimSuffix(parts[6], ".gz"), ".log")
+	case len(parts) == 6:
+		// network load balancers write no node ip
+		name.RandomString = strings.TrimSuffix(strings.TrimSuffix(parts[5], ".gz"), ".log")
+	}
+	return name, nil
+}
+
+func (a *AccessLogFilter) filterByTime(accessLog string) (bool, error) {
+	accessLogEndTimeStamp, err := accessLogEndTime(accessLog)
+	if err != nil {
+		return false, err
 	}
 	accessLogStartTimeStamp := accessLogEndTimeStamp.Add(-5 * time.Minute)
 
 	if (a.StartTime.Before(accessLogStartTimeStamp) || a.StartTime == accessLogStartTimeStamp) &&
 		(a.EndTime.After(accessLogEndTimeStamp) || accessLogEndTimeStamp == a.EndTime) {
 		Logger.Debugf("1 if. aEndTimeStamp: %v, endFilter: %v \n", accessLogEndTimeStamp.Format("15:04"), a.EndTime.Format("15:04"))
-		return true
+		return true, nil
 	} else if (a.StartTime.After(accessLogStartTimeStamp) && a.StartTime.Before(accessLogEndTimeStamp)) &&
 		(a.EndTime.Before(accessLogEndTimeStamp) && a.EndTime.After(a.StartTime)) {
 		Logger.Debugln("2 if")
-		return true
+		return true, nil
 	} else if (a.StartTime.Before(accessLogStartTimeStamp) || a.StartTime == accessLogStartTimeStamp) &&
 		(a.EndTime.Before(accessLogEndTimeStamp) && a.EndTime.After(a.StartTime) && a.EndTime.After(accessLogStartTimeStamp)) {
 		Logger.Debugln("3 if")
-		return true
+		return true, nil
 	} else if (a.EndTime.After(accessLogEndTimeStamp) || a.EndTime == accessLogEndTimeStamp) &&
 		(a.StartTime.After(accessLogStartTimeStamp) && a.StartTime.Before(accessLogEndTimeStamp)) {
 		Logger.Debugln("4 if")
-		return true
+		return true, nil
 	}
-	return false
+	return false, nil
 }
 
 // NewAccessLogFilter Return AccessLogFilter
-func NewAccessLogFilter() AccessLogFilter {
+func NewAccessLogFilter(opts AccessLogFilterOptions) (AccessLogFilter, error) {
 
-	startTime, err := time.Parse("2006-01-02 15:04:05", viper.GetString("start-time"))
+	startTime, endTime, err := parseTimeRange(opts)
 	if err != nil {
-		Logger.Fatalf("Failed to parse start time. Gott error: %v", err)
+		return AccessLogFilter{}, err
 	}
-	endTime, err := time.Parse("2006-01-02 15:04:05", viper.GetString("end-time"))
-	if err != nil {
-		Logger.Fatalf("Failed to parse end time. Gott error: %v", err)
+	logKind := LogKind(opts.LogKind)
+	if logKind == "" {
+		logKind = LogKindAccess
+	}
+	if logKind != LogKindAccess && logKind != LogKindConnection {
+		return AccessLogFilter{}, fmt.Errorf("%w: unknown log kind %q, should be %v or %v", ErrBadFilter, logKind, LogKindAccess, LogKindConnection)
+	}
+	if err := validKeyTemplate(opts.KeyTemplate); err != nil {
+		return AccessLogFilter{}, err
 	}
 	accessLogFilter := AccessLogFilter{}
-	accessLogFilter.AwsAccountID = viper.GetString("aws-account-id")
-	accessLogFilter.Region = viper.GetString("region")
-	accessLogFilter.StartTime = startTime // time.Now()
+	accessLogFilter.LogKind = logKind
+	accessLogFilter.KeyTemplate = opts.KeyTemplate
+	accessLogFilter.OrgID = opts.OrgID
+	accessLogFilter.AwsAccountID = opts.AwsAccountID
+	accessLogFilter.Region = opts.Region
+	accessLogFilter.StartTime = startTime
 	accessLogFilter.EndTime = endTime
-	accessLogFilter.LoadBalancerID = viper.GetString("load-balancer-id")
-	accessLogFilter.IPaddress = viper.GetString("ip-address")
-	accessLogFilter.RandomString = viper.GetString("random-string")
+	accessLogFilter.LoadBalancerID = opts.LoadBalancerID
+	accessLogFilter.IPaddress = opts.IPaddress
+	accessLogFilter.RandomString = opts.RandomString
+
+	return accessLogFilter, nil
+}
+
+// parseTimeRange return the start and end time of the options in UTC
+func parseTimeRange(opts AccessLogFilterOptions) (time.Time, time.Time, error) {
+	loc := time.UTC
+	if opts.TZ != "" {
+		var err error
+		if loc, err = time.LoadLocation(opts.TZ); err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: bad timezone: %v", ErrBadFilter, err)
+		}
+	}
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	start, end := opts.StartTime, opts.EndTime
+	if opts.Since != "" {
+		if start != "" {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: since can not be used together with start time", ErrBadFilter)
+		}
+		start = opts.Since
+		if d, err := ParseDuration(opts.Since); err == nil {
+			start = fmt.Sprintf("now-%v", d)
+		}
+	}
+	if opts.Last != "" {
+		if start != "" || end != "" {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: last can not be used together with start time, end time or since", ErrBadFilter)
+		}
+		d, err := ParseDuration(opts.Last)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("%w: failed to parse last: %v", ErrBadFilter, err)
+		}
+		start, end = fmt.Sprintf("now-%v", d), "now"
+	}
+	if start == "" {
+		start = "today"
+	}
+	if end == "" {
+		end = "now"
+	}
 
-	return accessLogFilter
+	startTime, err := ParseTime(start, now, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: failed to parse start time: %v", ErrBadFilter, err)
+	}
+	endTime, err := ParseTime(end, now, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: failed to parse end time: %v", ErrBadFilter, err)
+	}
+	if endTime.Before(startTime) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: end time %v is before start time %v", ErrBadFilter, endTime.UTC(), startTime.UTC())
+	}
+	return startTime.UTC(), endTime.UTC(), nil
+}
+
+// WithSession makes the LogWorker use sess instead of creating a new aws session
+func WithSession(sess *session.Session) Option {
+	return func(l *LogWorker) {
+		l.session = sess
+	}
 }
 
-// NewConfiguration return Configuration
-func NewConfiguration() Configuration {
-	return Configuration{
-		Bucket:          viper.GetString("s3-bucket"),
-		Prefix:          viper.GetString("s3-prefix"),
-		PollingInterval: viper.GetDuration("polling-interval"),
-		MaxKeys:         viper.GetInt64("max-keys"),
+// WithSource makes the LogWorker read the logs from src instead of s3
+func WithSource(src Source) Option {
+	return func(l *LogWorker) {
+		l.Source = src
 	}
 }
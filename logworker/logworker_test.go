@@ -1,7 +1,9 @@
 package logworker
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -23,7 +25,7 @@ func TestAccessLogFilterAccesslogPath(t *testing.T) {
 				StartTime:    time.Now(),
 			},
 			"",
-			fmt.Sprintf("AWSLogs/00000000111111/elasticloadbalancing/eu-west-1/%s/", time.Now().Format("2006/01/02")),
+			fmt.Sprintf("AWSLogs/00000000111111/elasticloadbalancing/eu-west-1/%s/", time.Now().UTC().Format("2006/01/02")),
 		},
 		{"WithPrefix",
 			AccessLogFilter{
@@ -33,7 +35,7 @@ func TestAccessLogFilterAccesslogPath(t *testing.T) {
 				StartTime:    time.Now(),
 			},
 			"team-xxx",
-			fmt.Sprintf("team-xxx/AWSLogs/00000000111111/elasticloadbalancing/eu-west-1/%s/", time.Now().Format("2006/01/02")),
+			fmt.Sprintf("team-xxx/AWSLogs/00000000111111/elasticloadbalancing/eu-west-1/%s/", time.Now().UTC().Format("2006/01/02")),
 		},
 	}
 
@@ -47,6 +49,63 @@ func TestAccessLogFilterAccesslogPath(t *testing.T) {
 
 }
 
+func TestAccessLogFilterAccesslogPaths(t *testing.T) {
+	tt := []struct {
+		name      string
+		startTime time.Time
+		endTime   time.Time
+		out       []string
+	}{
+		{
+			"OneDay",
+			time.Date(2019, 3, 3, 11, 0, 0, 0, time.UTC),
+			time.Date(2019, 3, 3, 12, 0, 0, 0, time.UTC),
+			[]string{"AWSLogs/0123456789/elasticloadbalancing/eu-west-1/2019/03/03/"},
+		},
+		{
+			"OverMidnight",
+			time.Date(2019, 3, 3, 22, 0, 0, 0, time.UTC),
+			time.Date(2019, 3, 4, 2, 0, 0, 0, time.UTC),
+			[]string{
+				"AWSLogs/0123456789/elasticloadbalancing/eu-west-1/2019/03/03/",
+				"AWSLogs/0123456789/elasticloadbalancing/eu-west-1/2019/03/04/",
+			},
+		},
+		{
+			"EndCloseToMidnight",
+			time.Date(2019, 3, 3, 22, 0, 0, 0, time.UTC),
+			time.Date(2019, 3, 3, 23, 57, 0, 0, time.UTC),
+			[]string{
+				"AWSLogs/0123456789/elasticloadbalancing/eu-west-1/2019/03/03/",
+				"AWSLogs/0123456789/elasticloadbalancing/eu-west-1/2019/03/04/",
+			},
+		},
+		{
+			"ThreeDays",
+			time.Date(2019, 3, 1, 12, 0, 0, 0, time.UTC),
+			time.Date(2019, 3, 3, 12, 0, 0, 0, time.UTC),
+			[]string{
+				"AWSLogs/0123456789/elasticloadbalancing/eu-west-1/2019/03/01/",
+				"AWSLogs/0123456789/elasticloadbalancing/eu-west-1/2019/03/02/",
+				"AWSLogs/0123456789/elasticloadbalancing/eu-west-1/2019/03/03/",
+			},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			accessLogFilter := AccessLogFilter{
+				AwsAccountID: "0123456789",
+				Region:       "eu-west-1",
+				StartTime:    tc.startTime,
+				EndTime:      tc.endTime,
+			}
+			if got := accessLogFilter.AccesslogPaths(""); !reflect.DeepEqual(got, tc.out) {
+				t.Fatalf("AccesslogPaths should be %v; got %v", tc.out, got)
+			}
+		})
+	}
+}
+
 func TestMatcher(t *testing.T) {
 	tt := []struct {
 		name           string
@@ -84,6 +143,15 @@ func TestMatcher(t *testing.T) {
 			".*",
 			"0123456789_elasticloadbalancing_eu-west-1_elb-prod.32435435_20190223T1610Z_10.205.19.102_34cjbbr9.log.gz",
 		},
+		{
+			"match-uncompressed-classic",
+			".*",
+			".*",
+			"my-classic-elb",
+			".*",
+			".*",
+			"0123456789_elasticloadbalancing_eu-west-1_my-classic-elb_20190223T1455Z_10.205.19.102_5ab4hl7r.log",
+		},
 	}
 
 	for _, tc := range tt {
@@ -94,7 +162,10 @@ func TestMatcher(t *testing.T) {
 			accessLogFilter.LoadBalancerID = tc.LoadBalancerID
 			accessLogFilter.IPaddress = tc.IPaddress
 			accessLogFilter.RandomString = tc.RandomString
-			matcher := newFilter(&accessLogFilter)
+			matcher, err := newFilter(&accessLogFilter)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
 			if !matcher.MatchString(tc.in) {
 				t.Fatalf("")
 			}
@@ -102,6 +173,75 @@ func TestMatcher(t *testing.T) {
 	}
 }
 
+func TestMatchNameLogKind(t *testing.T) {
+	tt := []struct {
+		name    string
+		logKind LogKind
+		in      string
+		out     bool
+	}{
+		{"access-accesslog", LogKindAccess, "0123456789_elasticloadbalancing_eu-west-1_app.elb-prod.32435435_20190223T1610Z_10.205.19.102_34cjbbr9.log.gz", true},
+		{"empty-kind-is-access", "", "0123456789_elasticloadbalancing_eu-west-1_app.elb-prod.32435435_20190223T1610Z_10.205.19.102_34cjbbr9.log.gz", true},
+		{"access-connectionlog", LogKindAccess, "conn_log.0123456789_elasticloadbalancing_eu-west-1_app.elb-prod.32435435_20190223T1610Z_10.205.19.102_34cjbbr9.log.gz", false},
+		{"connection-connectionlog", LogKindConnection, "conn_log.0123456789_elasticloadbalancing_eu-west-1_app.elb-prod.32435435_20190223T1610Z_10.205.19.102_34cjbbr9.log.gz", true},
+		{"connection-accesslog", LogKindConnection, "0123456789_elasticloadbalancing_eu-west-1_app.elb-prod.32435435_20190223T1610Z_10.205.19.102_34cjbbr9.log.gz", false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			accessLogFilter := AccessLogFilter{
+				LogKind:        tc.logKind,
+				AwsAccountID:   ".*",
+				Region:         ".*",
+				LoadBalancerID: ".*",
+				IPaddress:      ".*",
+				RandomString:   ".*",
+			}
+			matcher, err := newFilter(&accessLogFilter)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			accessLogFilter.matcher = matcher
+			if accessLogFilter.matchName(tc.in) != tc.out {
+				t.Fatalf("matchName(%v) with log kind %q should be %v", tc.in, tc.logKind, tc.out)
+			}
+		})
+	}
+}
+
+func TestMatchNameNetworkLoadBalancer(t *testing.T) {
+	nlbLog := "0123456789_elasticloadbalancing_eu-west-1_net.my-nlb.1a2b3c4d5e6f7a8b_20190223T1455Z_3f2a9c1d.log.gz"
+	tt := []struct {
+		name      string
+		ipAddress string
+		in        string
+		out       bool
+	}{
+		{"any-ip", ".*", nlbLog, true},
+		{"ip", "10.205.19.102", nlbLog, false},
+		{"ip-alb", "10.205.19.102", "0123456789_elasticloadbalancing_eu-west-1_app.elb-prod.32435435_20190223T1455Z_10.205.19.102_34cjbbr9.log.gz", true},
+		{"alb-without-ip", ".*", "0123456789_elasticloadbalancing_eu-west-1_app.elb-prod.32435435_20190223T1455Z_34cjbbr9.log.gz", false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			accessLogFilter := AccessLogFilter{
+				AwsAccountID:   ".*",
+				Region:         ".*",
+				LoadBalancerID: ".*",
+				IPaddress:      tc.ipAddress,
+				RandomString:   ".*",
+			}
+			matcher, err := newFilter(&accessLogFilter)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			accessLogFilter.matcher = matcher
+			if accessLogFilter.matchName(tc.in) != tc.out {
+				t.Fatalf("matchName(%v) with ip address %q should be %v", tc.in, tc.ipAddress, tc.out)
+			}
+		})
+	}
+}
+
 func TestStartTimeEndTime(t *testing.T) {
 	tt := []struct {
 		name      string
@@ -159,6 +299,13 @@ func TestStartTimeEndTime(t *testing.T) {
 			"0123456789_elasticloadbalancing_eu-west-1_elb-prod.32435435_20190223T1455Z_10.205.19.102_34cjbbr9.log.gz",
 			true,
 		},
+		{
+			"TrueConnectionLog",
+			"2019-02-23 14:45:00",
+			"2019-02-23 14:54:00",
+			"conn_log.0123456789_elasticloadbalancing_eu-west-1_app.elb-prod.32435435_20190223T1455Z_10.205.19.102_34cjbbr9.log.gz",
+			true,
+		},
 		{
 			"TrueEndFilterBeforeAcessLogEndTimestamp",
 			"2019-02-23 14:45:00",
@@ -175,7 +322,11 @@ func TestStartTimeEndTime(t *testing.T) {
 			accessLogFilter.StartTime = sTime
 			accessLogFilter.EndTime = eTime
 
-			if !accessLogFilter.filterByTime(tc.in) == tc.out {
+			ok, err := accessLogFilter.filterByTime(tc.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ok != tc.out {
 				t.Fatalf("startTime: %v, endTime: %v, accesslog timestamp: %v",
 					sTime.Format("20060102T15:04Z"),
 					eTime.Format("20060102T15:04Z"),
@@ -184,3 +335,100 @@ func TestStartTimeEndTime(t *testing.T) {
 		})
 	}
 }
+
+func TestErrors(t *testing.T) {
+	accessLogFilter := AccessLogFilter{AwsAccountID: "("}
+	if _, err := newFilter(&accessLogFilter); !errors.Is(err, ErrBadFilter) {
+		t.Fatalf("newFilter with bad regexp should return ErrBadFilter; got %v", err)
+	}
+
+	for _, name := range []string{
+		"0123456789_elasticloadbalancing_eu-west-1.log.gz",
+		"0123456789_elasticloadbalancing_eu-west-1_elb-prod.32435435_2019-02-23_10.205.19.102_34cjbbr9.log.gz",
+	} {
+		if _, err := accessLogFilter.filterByTime(name); !errors.Is(err, ErrBadAccessLogName) {
+			t.Fatalf("filterByTime(%v) should return ErrBadAccessLogName; got %v", name, err)
+		}
+	}
+
+	awsErr := errors.New("AccessDenied")
+	var err error = &ListError{URL: "s3://lb-bucket/AWSLogs/", Err: awsErr}
+	if !errors.Is(err, ErrListFailed) || !errors.Is(err, awsErr) {
+		t.Fatalf("ListError should match ErrListFailed and wrap the aws error; got %v", err)
+	}
+}
+
+func TestNewAccessLogFilter(t *testing.T) {
+	tt := []struct {
+		name string
+		opts AccessLogFilterOptions
+		err  bool
+	}{
+		{"ok", AccessLogFilterOptions{StartTime: "2019-02-23 14:45:00", EndTime: "2019-02-23 14:54:00"}, false},
+		{"connection", AccessLogFilterOptions{StartTime: "2019-02-23 14:45:00", EndTime: "2019-02-23 14:54:00", LogKind: "connection"}, false},
+		{"bad-start-time", AccessLogFilterOptions{StartTime: "last tuesday", EndTime: "2019-02-23 14:54:00"}, true},
+		{"bad-end-time", AccessLogFilterOptions{StartTime: "2019-02-23 14:45:00", EndTime: "2019-02-23"}, true},
+		{"bad-log-kind", AccessLogFilterOptions{StartTime: "2019-02-23 14:45:00", EndTime: "2019-02-23 14:54:00", LogKind: "flow"}, true},
+		{"key-template", AccessLogFilterOptions{StartTime: "2019-02-23 14:45:00", EndTime: "2019-02-23 14:54:00", KeyTemplate: OrgKeyTemplate}, false},
+		{"bad-key-template", AccessLogFilterOptions{StartTime: "2019-02-23 14:45:00", EndTime: "2019-02-23 14:54:00", KeyTemplate: "{prefix}/{account}"}, true},
+		{"defaults", AccessLogFilterOptions{}, false},
+		{"last-with-start-time", AccessLogFilterOptions{StartTime: "2019-02-23 14:45:00", Last: "1h"}, true},
+		{"since-with-start-time", AccessLogFilterOptions{StartTime: "2019-02-23 14:45:00", Since: "1h"}, true},
+		{"bad-last", AccessLogFilterOptions{Last: "an hour"}, true},
+		{"bad-tz", AccessLogFilterOptions{TZ: "Mars/Olympus_Mons"}, true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			accessLogFilter, err := NewAccessLogFilter(tc.opts)
+			if (err != nil) != tc.err {
+				t.Fatalf("expected error: %v; got %v", tc.err, err)
+			}
+			if err == nil && accessLogFilter.LogKind == "" {
+				t.Fatalf("log kind should default to %v", LogKindAccess)
+			}
+		})
+	}
+}
+
+func TestParseAccessLogName(t *testing.T) {
+	tt := []struct {
+		name string
+		in   string
+		out  AccessLogName
+	}{
+		{
+			"accesslog",
+			"0123456789_elasticloadbalancing_eu-west-1_app.elb-prod.32435435_20190223T1455Z_10.205.19.102_34cjbbr9.log.gz",
+			AccessLogName{"0123456789", "eu-west-1", "app.elb-prod.32435435", time.Date(2019, 2, 23, 14, 55, 0, 0, time.UTC), "10.205.19.102", "34cjbbr9"},
+		},
+		{
+			"connection-log",
+			"conn_log.0123456789_elasticloadbalancing_us-east-1_app.elb-prod.32435435_20190223T1500Z_10.205.19.102_34cjbbr9.log.gz",
+			AccessLogName{"0123456789", "us-east-1", "app.elb-prod.32435435", time.Date(2019, 2, 23, 15, 0, 0, 0, time.UTC), "10.205.19.102", "34cjbbr9"},
+		},
+		{
+			"classic-uncompressed",
+			"0123456789_elasticloadbalancing_eu-west-1_my-classic-elb_20190223T1455Z_10.205.19.102_5ab4hl7r.log",
+			AccessLogName{"0123456789", "eu-west-1", "my-classic-elb", time.Date(2019, 2, 23, 14, 55, 0, 0, time.UTC), "10.205.19.102", "5ab4hl7r"},
+		},
+		{
+			"nlb-without-ip",
+			"0123456789_elasticloadbalancing_eu-west-1_net.my-nlb.1a2b3c4d5e6f7a8b_20190223T1455Z_3f2a9c1d.log.gz",
+			AccessLogName{"0123456789", "eu-west-1", "net.my-nlb.1a2b3c4d5e6f7a8b", time.Date(2019, 2, 23, 14, 55, 0, 0, time.UTC), "", "3f2a9c1d"},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			name, err := ParseAccessLogName(tc.in)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if name != tc.out {
+				t.Fatalf("ParseAccessLogName(%v) should be %+v; got %+v", tc.in, tc.out, name)
+			}
+		})
+	}
+	if _, err := ParseAccessLogName("access.log"); !errors.Is(err, ErrBadAccessLogName) {
+		t.Fatalf("ParseAccessLogName should return ErrBadAccessLogName; got %v", err)
+	}
+}
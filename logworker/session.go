@@ -0,0 +1,96 @@
+package logworker
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+func newSession(awsConfiguration *AWSconfiguration) (*session.Session, error) {
+	awsCfg := aws.Config{}
+	if awsConfiguration.Region != "" {
+		awsCfg.Region = &awsConfiguration.Region
+	}
+
+	awsSessionOpts := session.Options{
+		Config:                  awsCfg,
+		AssumeRoleTokenProvider: stscreds.StdinTokenProvider,
+		SharedConfigState:       session.SharedConfigEnable,
+	}
+
+	if awsConfiguration.Profile != "" {
+		awsSessionOpts.Profile = awsConfiguration.Profile
+	}
+
+	sess, err := session.NewSessionWithOptions(awsSessionOpts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create aws session: %w", err)
+	}
+
+	if awsConfiguration.RoleARN != "" {
+		creds := stscreds.NewCredentials(sess, awsConfiguration.RoleARN, func(p *stscreds.AssumeRoleProvider) {
+			if awsConfiguration.ExternalID != "" {
+				p.ExternalID = aws.String(awsConfiguration.ExternalID)
+			}
+			p.TokenProvider = stscreds.StdinTokenProvider
+		})
+		sess = sess.Copy(&aws.Config{Credentials: creds})
+	}
+	return sess, nil
+}
+
+// newS3Client return a s3 client for the region of the bucket. The bucket
+// region is looked up with GetBucketLocation, if that is not allowed the
+// region of the session is used and an error is returned when the session
+// has no region. A custom S3Endpoint is used as it is.
+func newS3Client(sess *session.Session, awsConfiguration *AWSconfiguration, bucket string) (*s3.S3, error) {
+	s3Cfg := s3Config(awsConfiguration)
+	client := s3.New(sess, s3Cfg)
+	if bucket == "" || awsConfiguration.S3Endpoint != "" {
+		return client, nil
+	}
+	lookup := client
+	if aws.StringValue(sess.Config.Region) == "" {
+		// GetBucketLocation can be called in any region
+		lookup = s3.New(sess, s3Cfg.Copy().WithRegion("us-east-1"))
+	}
+	out, err := lookup.GetBucketLocation(&s3.GetBucketLocationInput{
+		Bucket: aws.String(bucket),
+	})
+	if err != nil {
+		if aws.StringValue(sess.Config.Region) == "" {
+			return nil, fmt.Errorf("failed to look up the region of bucket %v, set --region: %w", bucket, err)
+		}
+		Logger.Debugf("GetBucketLocation of %v failed, using the session region. Got error: %v", bucket, err)
+		return client, nil
+	}
+	region := bucketRegion(out.LocationConstraint)
+	if region == aws.StringValue(sess.Config.Region) {
+		return client, nil
+	}
+	Logger.Debugf("bucket %v is in region %v", bucket, region)
+	return s3.New(sess, s3Cfg.Copy().WithRegion(region)), nil
+}
+
+// s3Config return the settings that is only for the s3 client, for s3 compatible stores
+func s3Config(awsConfiguration *AWSconfiguration) *aws.Config {
+	cfg := &aws.Config{}
+	if awsConfiguration.S3Endpoint != "" {
+		cfg.Endpoint = aws.String(awsConfiguration.S3Endpoint)
+	}
+	if awsConfiguration.S3ForcePathStyle {
+		cfg.S3ForcePathStyle = aws.Bool(true)
+	}
+	if awsConfiguration.S3DisableSSL {
+		cfg.DisableSSL = aws.Bool(true)
+	}
+	return cfg
+}
+
+// bucketRegion return the region of the LocationConstraint of a bucket
+func bucketRegion(locationConstraint *string) string {
+	return s3.NormalizeBucketLocation(aws.StringValue(locationConstraint))
+}
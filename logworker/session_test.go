@@ -0,0 +1,138 @@
+package logworker
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+func TestBucketRegion(t *testing.T) {
+	tt := []struct {
+		name     string
+		location *string
+		out      string
+	}{
+		{"us-east-1", nil, "us-east-1"},
+		{"empty", aws.String(""), "us-east-1"},
+		{"eu", aws.String("EU"), "eu-west-1"},
+		{"eu-north-1", aws.String("eu-north-1"), "eu-north-1"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := bucketRegion(tc.location); got != tc.out {
+				t.Fatalf("bucketRegion should be %v; got %v", tc.out, got)
+			}
+		})
+	}
+}
+
+func TestNewSession(t *testing.T) {
+	sess, err := newSession(&AWSconfiguration{Region: "ap-southeast-2"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := aws.StringValue(sess.Config.Region); got != "ap-southeast-2" {
+		t.Fatalf("region should be ap-southeast-2; got %v", got)
+	}
+
+	base := sess.Config.Credentials
+	sess, err = newSession(&AWSconfiguration{
+		Region:     "eu-north-1",
+		RoleARN:    "arn:aws:iam::0123456789:role/elblogcat",
+		ExternalID: "external",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sess.Config.Credentials == nil || sess.Config.Credentials == base {
+		t.Fatalf("assume role credentials should be used")
+	}
+}
+
+func TestS3SourceCustomEndpoint(t *testing.T) {
+	var requestPath string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestPath = r.URL.Path
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?>
+<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+  <Name>lb-bucket</Name>
+  <Prefix>AWSLogs/</Prefix>
+  <KeyCount>1</KeyCount>
+  <IsTruncated>false</IsTruncated>
+  <Contents><Key>AWSLogs/0123456789_elasticloadbalancing_eu-west-1_app.elb-prod.32435435_20190223T1455Z_10.205.19.102_34cjbbr9.log.gz</Key></Contents>
+</ListBucketResult>`)
+	}))
+	defer server.Close()
+
+	awsConfiguration := &AWSconfiguration{
+		Region:           "eu-west-1",
+		S3Endpoint:       server.URL,
+		S3ForcePathStyle: true,
+		S3DisableSSL:     true,
+	}
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String("eu-west-1"),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client, err := newS3Client(sess, awsConfiguration, "lb-bucket")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	src := NewS3Source(client, "lb-bucket", 10)
+	keys, err := src.List("AWSLogs/")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(keys) != 1 {
+		t.Fatalf("expected 1 key; got %v", keys)
+	}
+	if requestPath != "/lb-bucket" {
+		t.Fatalf("path style request should be to /lb-bucket; got %v", requestPath)
+	}
+}
+
+func TestNewS3ClientLookupFailed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?>
+<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`)
+	}))
+	defer server.Close()
+
+	tt := []struct {
+		name   string
+		region string
+		err    bool
+	}{
+		{"session-region", "eu-west-1", false},
+		{"no-region", "", true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			sess, err := session.NewSession(&aws.Config{
+				Region:           aws.String(tc.region),
+				Endpoint:         aws.String(server.URL),
+				S3ForcePathStyle: aws.Bool(true),
+				Credentials:      credentials.NewStaticCredentials("id", "secret", ""),
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			client, err := newS3Client(sess, &AWSconfiguration{}, "lb-bucket")
+			if (err != nil) != tc.err {
+				t.Fatalf("newS3Client error should be %v; got %v", tc.err, err)
+			}
+			if !tc.err && aws.StringValue(client.Config.Region) != tc.region {
+				t.Fatalf("client should use the session region %v; got %v", tc.region, aws.StringValue(client.Config.Region))
+			}
+		})
+	}
+}
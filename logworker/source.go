@@ -0,0 +1,151 @@
+package logworker
+
+import (
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+type (
+	// Source is the store that holds the logs, keys are slash separated paths
+	// like AWSLogs/<account>/elasticloadbalancing/<region>/YYYY/MM/DD/<log>
+	Source interface {
+		// List returns the keys that starts with prefix. Keys in sub
+		// directories of the prefix are not returned.
+		List(prefix string) ([]string, error)
+		// ListDirs returns the names of the sub directories of the directory prefix
+		ListDirs(prefix string) ([]string, error)
+		// Open return a stream of the object with key
+		Open(key string) (io.ReadCloser, error)
+	}
+
+	// S3Source is a Source that reads the logs from a s3 bucket
+	S3Source struct {
+		Client  *s3.S3
+		Bucket  string
+		MaxKeys int64
+	}
+
+	// FileSource is a Source that reads the logs from a local directory
+	// with the same layout as the s3 bucket
+	FileSource struct {
+		Root string
+	}
+)
+
+// NewS3Source return a S3Source for bucket
+func NewS3Source(client *s3.S3, bucket string, maxKeys int64) *S3Source {
+	return &S3Source{Client: client, Bucket: bucket, MaxKeys: maxKeys}
+}
+
+// List returns the keys that starts with prefix
+func (s *S3Source) List(prefix string) ([]string, error) {
+	var keys []string
+	input := &s3.ListObjectsV2Input{
+		Bucket:    aws.String(s.Bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	}
+	if s.MaxKeys > 0 {
+		input.MaxKeys = aws.Int64(s.MaxKeys)
+	}
+	err := s.Client.ListObjectsV2Pages(input,
+		func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+			for _, val := range page.Contents {
+				keys = append(keys, *val.Key)
+			}
+			return true
+		})
+	if err != nil {
+		return nil, &ListError{URL: "s3://" + path.Join(s.Bucket, prefix), Err: err}
+	}
+	return keys, nil
+}
+
+// ListDirs returns the names of the common prefixes under prefix
+func (s *S3Source) ListDirs(prefix string) ([]string, error) {
+	var dirs []string
+	err := s.Client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket:    aws.String(s.Bucket),
+		Prefix:    aws.String(prefix),
+		Delimiter: aws.String("/"),
+	},
+		func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+			for _, val := range page.CommonPrefixes {
+				dirs = append(dirs, strings.TrimSuffix(strings.TrimPrefix(*val.Prefix, prefix), "/"))
+			}
+			return true
+		})
+	if err != nil {
+		return nil, &ListError{URL: "s3://" + path.Join(s.Bucket, prefix), Err: err}
+	}
+	return dirs, nil
+}
+
+// Open return the body of the s3 object with key
+func (s *S3Source) Open(key string) (io.ReadCloser, error) {
+	out, err := s.Client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(s.Bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+// NewFileSource return a FileSource for the directory root
+func NewFileSource(root string) *FileSource {
+	return &FileSource{Root: root}
+}
+
+// List returns the keys of the files that starts with prefix
+func (f *FileSource) List(prefix string) ([]string, error) {
+	dir, namePrefix := path.Split(prefix)
+	entries, err := os.ReadDir(filepath.Join(f.Root, filepath.FromSlash(dir)))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &ListError{URL: "file://" + path.Join(filepath.ToSlash(f.Root), prefix), Err: err}
+	}
+	var keys []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), namePrefix) {
+			continue
+		}
+		keys = append(keys, dir+entry.Name())
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// ListDirs returns the names of the directories in the directory prefix
+func (f *FileSource) ListDirs(prefix string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(f.Root, filepath.FromSlash(prefix)))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &ListError{URL: "file://" + path.Join(filepath.ToSlash(f.Root), prefix), Err: err}
+	}
+	var dirs []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dirs = append(dirs, entry.Name())
+		}
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// Open opens the file with key
+func (f *FileSource) Open(key string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(f.Root, filepath.FromSlash(key)))
+}
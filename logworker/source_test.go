@@ -0,0 +1,291 @@
+package logworker
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func writeTestLogs(t *testing.T, root string, keys ...string) {
+	t.Helper()
+	for _, key := range keys {
+		p := filepath.Join(root, filepath.FromSlash(key))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, []byte(key), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	root := t.TempDir()
+	dir := "AWSLogs/0123456789/elasticloadbalancing/eu-west-1/2019/02/23/"
+	writeTestLogs(t, root,
+		dir+"0123456789_elasticloadbalancing_eu-west-1_app.elb-prod.32435435_20190223T1455Z_10.205.19.102_34cjbbr9.log.gz",
+		dir+"0123456789_elasticloadbalancing_eu-west-1_app.elb-prod.32435435_20190223T1500Z_10.205.19.102_34cjbbr9.log.gz",
+		dir+"sub/ignored.log.gz",
+	)
+	src := NewFileSource(root)
+
+	tt := []struct {
+		name   string
+		prefix string
+		out    []string
+	}{
+		{"dir", dir, []string{
+			dir + "0123456789_elasticloadbalancing_eu-west-1_app.elb-prod.32435435_20190223T1455Z_10.205.19.102_34cjbbr9.log.gz",
+			dir + "0123456789_elasticloadbalancing_eu-west-1_app.elb-prod.32435435_20190223T1500Z_10.205.19.102_34cjbbr9.log.gz",
+		}},
+		{"name-prefix", dir + "0123456789_elasticloadbalancing_eu-west-1_app.elb-prod.32435435_20190223T1500Z", []string{
+			dir + "0123456789_elasticloadbalancing_eu-west-1_app.elb-prod.32435435_20190223T1500Z_10.205.19.102_34cjbbr9.log.gz",
+		}},
+		{"missing-dir", "AWSLogs/0123456789/elasticloadbalancing/eu-west-1/2019/02/24/", nil},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			keys, err := src.List(tc.prefix)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(keys, tc.out) {
+				t.Fatalf("List(%v) should be %v; got %v", tc.prefix, tc.out, keys)
+			}
+		})
+	}
+
+	r, err := src.Open(tt[1].out[0])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer r.Close()
+	b, _ := ioutil.ReadAll(r)
+	if string(b) != tt[1].out[0] {
+		t.Fatalf("Open returned %q", b)
+	}
+}
+
+func TestLogWorkerWithFileSource(t *testing.T) {
+	root := t.TempDir()
+	dir := "team-xxx/AWSLogs/0123456789/elasticloadbalancing/eu-west-1/2019/02/23/"
+	writeTestLogs(t, root,
+		dir+"0123456789_elasticloadbalancing_eu-west-1_app.elb-prod.32435435_20190223T1455Z_10.205.19.102_34cjbbr9.log.gz",
+		dir+"0123456789_elasticloadbalancing_eu-west-1_app.elb-prod.32435435_20190223T1600Z_10.205.19.102_34cjbbr9.log.gz",
+		dir+"0123456789_elasticloadbalancing_eu-west-1_app.elb-test.32435435_20190223T1455Z_10.205.19.102_34cjbbr9.log.gz",
+	)
+	accessLogFilter := AccessLogFilter{
+		AwsAccountID:   "0123456789",
+		Region:         "eu-west-1",
+		LoadBalancerID: "app.elb-prod.*",
+		IPaddress:      ".*",
+		RandomString:   ".*",
+		StartTime:      time.Date(2019, 2, 23, 14, 45, 0, 0, time.UTC),
+		EndTime:        time.Date(2019, 2, 23, 15, 0, 0, 0, time.UTC),
+	}
+	client, err := NewLogWorker(&AWSconfiguration{}, &Configuration{Prefix: "team-xxx"}, &accessLogFilter, WithSource(NewFileSource(root)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	accessLogs, err := client.List()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"0123456789_elasticloadbalancing_eu-west-1_app.elb-prod.32435435_20190223T1455Z_10.205.19.102_34cjbbr9.log.gz"}
+	if !reflect.DeepEqual(accessLogs, want) {
+		t.Fatalf("List should return %v; got %v", want, accessLogs)
+	}
+	keys, err := client.ListKeys()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r, err := client.Open(keys[0])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	r.Close()
+}
+
+func TestLogWorkerListLogNames(t *testing.T) {
+	dir := "AWSLogs/0123456789/elasticloadbalancing/eu-west-1/2019/02/23/"
+	tt := []struct {
+		name           string
+		loadBalancerID string
+		key            string
+	}{
+		{"alb", "app.elb-prod.*", "0123456789_elasticloadbalancing_eu-west-1_app.elb-prod.32435435_20190223T1455Z_10.205.19.102_34cjbbr9.log.gz"},
+		{"classic-uncompressed", "my-classic-elb", "0123456789_elasticloadbalancing_eu-west-1_my-classic-elb_20190223T1455Z_10.205.19.102_5ab4hl7r.log"},
+		{"nlb-without-ip", "net.my-nlb.*", "0123456789_elasticloadbalancing_eu-west-1_net.my-nlb.1a2b3c4d5e6f7a8b_20190223T1455Z_3f2a9c1d.log.gz"},
+		{"nlb-load-balancer-id", "net.my-nlb.1a2b3c4d5e6f7a8b", "0123456789_elasticloadbalancing_eu-west-1_net.my-nlb.1a2b3c4d5e6f7a8b_20190223T1455Z_3f2a9c1d.log.gz"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeTestLogs(t, root,
+				dir+tc.key,
+				dir+"0123456789_elasticloadbalancing_eu-west-1_app.elb-test.32435435_20190223T1455Z_10.205.19.102_34cjbbr9.log.gz",
+				dir+"0123456789_elasticloadbalancing_eu-west-1_app.elb-test.32435435_20190223T1455Z_34cjbbr9.log.gz",
+				dir+"0123456789_elasticloadbalancing_eu-west-1_my-classic-elb_20190223T1455Z_10.205.19.102_5ab4hl7r.log.txt",
+			)
+			accessLogFilter := AccessLogFilter{
+				AwsAccountID:   "0123456789",
+				Region:         "eu-west-1",
+				LoadBalancerID: tc.loadBalancerID,
+				IPaddress:      ".*",
+				RandomString:   ".*",
+				StartTime:      time.Date(2019, 2, 23, 14, 45, 0, 0, time.UTC),
+				EndTime:        time.Date(2019, 2, 23, 15, 0, 0, 0, time.UTC),
+			}
+			client, err := NewLogWorker(&AWSconfiguration{}, &Configuration{}, &accessLogFilter, WithSource(NewFileSource(root)))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			accessLogs, err := client.List()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if want := []string{tc.key}; !reflect.DeepEqual(accessLogs, want) {
+				t.Fatalf("List should return %v; got %v", want, accessLogs)
+			}
+		})
+	}
+}
+
+func TestLogWorkerListSpanningDays(t *testing.T) {
+	root := t.TempDir()
+	day := "AWSLogs/0123456789/elasticloadbalancing/eu-west-1/2019/03/%s/0123456789_elasticloadbalancing_eu-west-1_app.elb-prod.32435435_%s_10.205.19.102_34cjbbr9.log.gz"
+	writeTestLogs(t, root,
+		fmt.Sprintf(day, "03", "20190303T2155Z"),
+		fmt.Sprintf(day, "03", "20190303T2205Z"),
+		fmt.Sprintf(day, "03", "20190303T2355Z"),
+		fmt.Sprintf(day, "04", "20190304T0000Z"),
+		fmt.Sprintf(day, "04", "20190304T0200Z"),
+		fmt.Sprintf(day, "04", "20190304T0210Z"),
+		fmt.Sprintf(day, "05", "20190305T0000Z"),
+	)
+	accessLogFilter := AccessLogFilter{
+		AwsAccountID:   "0123456789",
+		Region:         "eu-west-1",
+		LoadBalancerID: ".*",
+		IPaddress:      ".*",
+		RandomString:   ".*",
+		StartTime:      time.Date(2019, 3, 3, 22, 0, 0, 0, time.UTC),
+		EndTime:        time.Date(2019, 3, 4, 2, 0, 0, 0, time.UTC),
+	}
+	client, err := NewLogWorker(&AWSconfiguration{}, &Configuration{}, &accessLogFilter, WithSource(NewFileSource(root)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	keys, err := client.ListKeys()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{
+		fmt.Sprintf(day, "03", "20190303T2205Z"),
+		fmt.Sprintf(day, "03", "20190303T2355Z"),
+		fmt.Sprintf(day, "04", "20190304T0000Z"),
+		fmt.Sprintf(day, "04", "20190304T0200Z"),
+	}
+	if !reflect.DeepEqual(keys, want) {
+		t.Fatalf("ListKeys should return %v; got %v", want, keys)
+	}
+}
+
+func TestLogWorkerMultipleTargets(t *testing.T) {
+	root := t.TempDir()
+	key := "AWSLogs/%[1]s/elasticloadbalancing/%[2]s/2019/02/23/%[1]s_elasticloadbalancing_%[2]s_app.elb-prod.32435435_20190223T1455Z_10.205.19.102_34cjbbr9.log.gz"
+	writeTestLogs(t, root,
+		fmt.Sprintf(key, "0123456789", "eu-west-1"),
+		fmt.Sprintf(key, "0123456789", "us-east-1"),
+		fmt.Sprintf(key, "1111111111", "eu-west-1"),
+		fmt.Sprintf(key, "2222222222", "eu-north-1"),
+	)
+
+	tt := []struct {
+		name    string
+		account string
+		region  string
+		targets []Target
+		keys    []string
+	}{
+		{
+			"lists",
+			"0123456789,1111111111",
+			"eu-west-1",
+			[]Target{{AwsAccountID: "0123456789", Region: "eu-west-1"}, {AwsAccountID: "1111111111", Region: "eu-west-1"}},
+			[]string{fmt.Sprintf(key, "0123456789", "eu-west-1"), fmt.Sprintf(key, "1111111111", "eu-west-1")},
+		},
+		{
+			"all-regions",
+			"0123456789",
+			"all",
+			[]Target{{AwsAccountID: "0123456789", Region: "eu-west-1"}, {AwsAccountID: "0123456789", Region: "us-east-1"}},
+			[]string{fmt.Sprintf(key, "0123456789", "eu-west-1"), fmt.Sprintf(key, "0123456789", "us-east-1")},
+		},
+		{
+			"all",
+			"all",
+			"all",
+			[]Target{{AwsAccountID: "0123456789", Region: "eu-west-1"}, {AwsAccountID: "0123456789", Region: "us-east-1"}, {AwsAccountID: "1111111111", Region: "eu-west-1"}, {AwsAccountID: "2222222222", Region: "eu-north-1"}},
+			[]string{
+				fmt.Sprintf(key, "0123456789", "eu-west-1"),
+				fmt.Sprintf(key, "0123456789", "us-east-1"),
+				fmt.Sprintf(key, "1111111111", "eu-west-1"),
+				fmt.Sprintf(key, "2222222222", "eu-north-1"),
+			},
+		},
+		{
+			"all-accounts-one-region",
+			"all",
+			"eu-north-1,eu-west-1",
+			[]Target{
+				{AwsAccountID: "0123456789", Region: "eu-north-1"}, {AwsAccountID: "0123456789", Region: "eu-west-1"},
+				{AwsAccountID: "1111111111", Region: "eu-north-1"}, {AwsAccountID: "1111111111", Region: "eu-west-1"},
+				{AwsAccountID: "2222222222", Region: "eu-north-1"}, {AwsAccountID: "2222222222", Region: "eu-west-1"},
+			},
+			[]string{
+				fmt.Sprintf(key, "0123456789", "eu-west-1"),
+				fmt.Sprintf(key, "1111111111", "eu-west-1"),
+				fmt.Sprintf(key, "2222222222", "eu-north-1"),
+			},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			accessLogFilter := AccessLogFilter{
+				AwsAccountID:   tc.account,
+				Region:         tc.region,
+				LoadBalancerID: ".*",
+				IPaddress:      ".*",
+				RandomString:   ".*",
+				StartTime:      time.Date(2019, 2, 23, 14, 45, 0, 0, time.UTC),
+				EndTime:        time.Date(2019, 2, 23, 15, 0, 0, 0, time.UTC),
+			}
+			client, err := NewLogWorker(&AWSconfiguration{}, &Configuration{}, &accessLogFilter, WithSource(NewFileSource(root)))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			targets, err := client.Targets()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(targets, tc.targets) {
+				t.Fatalf("Targets should return %v; got %v", tc.targets, targets)
+			}
+			keys, err := client.ListKeys()
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(keys, tc.keys) {
+				t.Fatalf("ListKeys should return %v; got %v", tc.keys, keys)
+			}
+			if !accessLogFilter.MultipleTargets() {
+				t.Fatalf("MultipleTargets should be true for %s/%s", tc.account, tc.region)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package logworker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeLayouts is the absolute times accepted by ParseTime, the ones without
+// zone are in the location given to ParseTime
+var timeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+}
+
+// clockLayouts is the times of day accepted by ParseTime, they are today in the location
+var clockLayouts = []string{
+	"15:04:05",
+	"15:04",
+}
+
+// ParseTime parses a time expression, it can be
+//
+//	now, now-45m, now+1h         relative to now
+//	today, yesterday             midnight in loc
+//	2019-03-03T11:00:00+01:00    RFC3339 with its own zone
+//	2019-03-03 11:00:00          date and time in loc, seconds and time are optional
+//	11:00:00, 11:00              time of the current day in loc
+func ParseTime(expr string, now time.Time, loc *time.Location) (time.Time, error) {
+	expr = strings.TrimSpace(expr)
+	if loc == nil {
+		loc = time.UTC
+	}
+	now = now.In(loc)
+	switch {
+	case expr == "now":
+		return now, nil
+	case strings.HasPrefix(expr, "now-"), strings.HasPrefix(expr, "now+"):
+		d, err := ParseDuration(expr[4:])
+		if err != nil {
+			return time.Time{}, err
+		}
+		if expr[3] == '-' {
+			d = -d
+		}
+		return now.Add(d), nil
+	case expr == "today":
+		return midnight(now), nil
+	case expr == "yesterday":
+		return midnight(now).AddDate(0, 0, -1), nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, expr, loc); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range clockLayouts {
+		if t, err := time.ParseInLocation(layout, expr, loc); err == nil {
+			y, m, d := now.Date()
+			return time.Date(y, m, d, t.Hour(), t.Minute(), t.Second(), 0, loc), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("bad time %q, should be now, now-<duration>, today, RFC3339, 2006-01-02 15:04:05 or 15:04", expr)
+}
+
+// ParseDuration parses a duration like time.ParseDuration, days are written as 2d
+func ParseDuration(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("bad duration %q: %v", s, err)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("bad duration %q: %v", s, err)
+	}
+	return d, nil
+}
+
+func midnight(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
@@ -0,0 +1,82 @@
+package logworker
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	stockholm, err := time.LoadLocation("Europe/Stockholm")
+	if err != nil {
+		t.Skipf("no tz database: %v", err)
+	}
+	now := time.Date(2019, 3, 3, 12, 30, 0, 0, time.UTC)
+	tt := []struct {
+		expr string
+		loc  *time.Location
+		out  time.Time
+	}{
+		{"now", nil, now},
+		{"now-45m", nil, now.Add(-45 * time.Minute)},
+		{"now+1h", nil, now.Add(time.Hour)},
+		{"now-2d", nil, now.Add(-48 * time.Hour)},
+		{"today", nil, time.Date(2019, 3, 3, 0, 0, 0, 0, time.UTC)},
+		{"yesterday", nil, time.Date(2019, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{"today", stockholm, time.Date(2019, 3, 2, 23, 0, 0, 0, time.UTC)},
+		{"2019-03-03T11:00:00+01:00", nil, time.Date(2019, 3, 3, 10, 0, 0, 0, time.UTC)},
+		{"2019-03-03T11:00:00.5Z", stockholm, time.Date(2019, 3, 3, 11, 0, 0, 500000000, time.UTC)},
+		{"2019-03-03 11:00:00", nil, time.Date(2019, 3, 3, 11, 0, 0, 0, time.UTC)},
+		{"2019-03-03 11:00", stockholm, time.Date(2019, 3, 3, 10, 0, 0, 0, time.UTC)},
+		{"2019-03-03", nil, time.Date(2019, 3, 3, 0, 0, 0, 0, time.UTC)},
+		{"14:00", stockholm, time.Date(2019, 3, 3, 13, 0, 0, 0, time.UTC)},
+		{"14:00:30", nil, time.Date(2019, 3, 3, 14, 0, 30, 0, time.UTC)},
+	}
+	for _, tc := range tt {
+		t.Run(tc.expr, func(t *testing.T) {
+			got, err := ParseTime(tc.expr, now, tc.loc)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !got.Equal(tc.out) {
+				t.Fatalf("ParseTime(%v) should be %v; got %v", tc.expr, tc.out, got.UTC())
+			}
+		})
+	}
+	for _, expr := range []string{"", "last tuesday", "now-1y", "25:00"} {
+		if _, err := ParseTime(expr, now, nil); err == nil {
+			t.Fatalf("ParseTime(%q) should fail", expr)
+		}
+	}
+}
+
+func TestParseTimeRange(t *testing.T) {
+	now := time.Date(2019, 3, 3, 12, 30, 0, 0, time.UTC)
+	tt := []struct {
+		name  string
+		opts  AccessLogFilterOptions
+		start time.Time
+		end   time.Time
+	}{
+		{"defaults", AccessLogFilterOptions{}, time.Date(2019, 3, 3, 0, 0, 0, 0, time.UTC), now},
+		{"since-duration", AccessLogFilterOptions{Since: "45m"}, now.Add(-45 * time.Minute), now},
+		{"since-time", AccessLogFilterOptions{Since: "11:00"}, time.Date(2019, 3, 3, 11, 0, 0, 0, time.UTC), now},
+		{"last", AccessLogFilterOptions{Last: "2h"}, now.Add(-2 * time.Hour), now},
+		{"tz", AccessLogFilterOptions{StartTime: "12:00", EndTime: "13:00", TZ: "Europe/Stockholm"}, time.Date(2019, 3, 3, 11, 0, 0, 0, time.UTC), time.Date(2019, 3, 3, 12, 0, 0, 0, time.UTC)},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.opts.Now = now
+			start, end, err := parseTimeRange(tc.opts)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !start.Equal(tc.start) || !end.Equal(tc.end) {
+				t.Fatalf("time range should be %v - %v; got %v - %v", tc.start, tc.end, start, end)
+			}
+		})
+	}
+	if _, _, err := parseTimeRange(AccessLogFilterOptions{StartTime: "now", EndTime: "now-1h"}); !errors.Is(err, ErrBadFilter) {
+		t.Fatalf("end time before start time should return ErrBadFilter; got %v", err)
+	}
+}